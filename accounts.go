@@ -0,0 +1,28 @@
+package main
+
+import "github.com/google/go-github/github"
+
+// accountFilter drops the issues, pull requests, comments and reviews made
+// by some accounts, such as bots and automation that files issues en masse.
+// It does not drop issue events, which make up the states of issues.
+type accountFilter struct {
+	bots bool
+	// logins are logins, or login prefixes ending with "*", to drop.
+	logins labelPatterns
+}
+
+// Match reports whether the user is kept. A nil filter keeps everyone.
+func (f *accountFilter) Match(u *github.User) bool {
+	if f == nil || u == nil {
+		return true
+	}
+	if f.bots && isBot(u) {
+		return false
+	}
+	for _, pattern := range f.logins {
+		if matchLabel(pattern, u.GetLogin()) {
+			return false
+		}
+	}
+	return true
+}
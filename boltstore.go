@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	boltRecordsBucket = []byte("records")
+	boltTimesBucket   = []byte("times")
+	boltSyncTimeKey   = []byte("sync_time")
+)
+
+// boltStore saves records into a single bolt database file.
+//
+// Each repo has a top-level bucket, which has a bucket for each kind.
+// A kind bucket holds the sync time, a "records" bucket that maps record
+// key to the encoded record time followed by the JSON data, and a "times"
+// bucket that indexes record keys by the encoded record time.
+//
+// The file is opened for each call and closed after it, because bolt locks
+// the file while it is open, and other processes sharing it, e.g., a serve
+// mode running for days, would wait for it forever.
+type boltStore struct {
+	filename string
+}
+
+func newBoltStore(filename string) (*boltStore, error) {
+	s := &boltStore{filename: filename}
+	// fail early on a file that can't be opened
+	if err := s.use(func(db *bolt.DB) error { return nil }); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// boltTimeout is how long to wait for other processes sharing the file to
+// release it.
+const boltTimeout = time.Minute
+
+// use calls f with the database opened.
+func (s *boltStore) use(f func(db *bolt.DB) error) error {
+	db, err := bolt.Open(s.filename, 0600, &bolt.Options{Timeout: boltTimeout})
+	if err == bolt.ErrTimeout {
+		return fmt.Errorf("database %s is still locked by another process after %v", s.filename, boltTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("error opening database %s (%v)", s.filename, err)
+	}
+	defer db.Close()
+	return f(db)
+}
+
+func (s *boltStore) view(f func(tx *bolt.Tx) error) error {
+	return s.use(func(db *bolt.DB) error { return db.View(f) })
+}
+
+func (s *boltStore) update(f func(tx *bolt.Tx) error) error {
+	return s.use(func(db *bolt.DB) error { return db.Update(f) })
+}
+
+func (s *boltStore) SyncTime(repo, kind string) (time.Time, error) {
+	var t time.Time
+	err := s.view(func(tx *bolt.Tx) error {
+		b := kindBucket(tx, repo, kind)
+		if b == nil {
+			return nil
+		}
+		if v := b.Get(boltSyncTimeKey); v != nil {
+			return t.UnmarshalBinary(v)
+		}
+		return nil
+	})
+	return t, err
+}
+
+func (s *boltStore) Put(repo, kind string, records []record, syncedAt time.Time) error {
+	return s.update(func(tx *bolt.Tx) error {
+		rb, err := tx.CreateBucketIfNotExists([]byte(repo))
+		if err != nil {
+			return err
+		}
+		b, err := rb.CreateBucketIfNotExists([]byte(kind))
+		if err != nil {
+			return err
+		}
+		recs, err := b.CreateBucketIfNotExists(boltRecordsBucket)
+		if err != nil {
+			return err
+		}
+		times, err := b.CreateBucketIfNotExists(boltTimesBucket)
+		if err != nil {
+			return err
+		}
+		for _, r := range records {
+			key := []byte(r.Key)
+			if old := recs.Get(key); old != nil {
+				if err := times.Delete(timeIndexKey(old[:8], key)); err != nil {
+					return err
+				}
+			}
+			data, err := json.Marshal(r.Value)
+			if err != nil {
+				return err
+			}
+			tk := timeKey(r.Time)
+			if err := recs.Put(key, append(tk, data...)); err != nil {
+				return err
+			}
+			if err := times.Put(timeIndexKey(tk, key), nil); err != nil {
+				return err
+			}
+		}
+		st, err := syncedAt.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return b.Put(boltSyncTimeKey, st)
+	})
+}
+
+func (s *boltStore) Walk(repo, kind string, start, end time.Time, f func(data []byte) error) error {
+	return s.view(func(tx *bolt.Tx) error {
+		b := kindBucket(tx, repo, kind)
+		if b == nil {
+			return nil
+		}
+		recs, times := b.Bucket(boltRecordsBucket), b.Bucket(boltTimesBucket)
+		if recs == nil || times == nil {
+			return nil
+		}
+		c := times.Cursor()
+		k, _ := c.First()
+		if !start.IsZero() {
+			k, _ = c.Seek(timeKey(start))
+		}
+		for ; k != nil; k, _ = c.Next() {
+			if !end.IsZero() && bytes.Compare(k[:8], timeKey(end)) >= 0 {
+				break
+			}
+			v := recs.Get(k[8:])
+			if v == nil {
+				continue
+			}
+			if err := f(v[8:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func kindBucket(tx *bolt.Tx, repo, kind string) *bolt.Bucket {
+	rb := tx.Bucket([]byte(repo))
+	if rb == nil {
+		return nil
+	}
+	return rb.Bucket([]byte(kind))
+}
+
+// timeKey encodes t into 8 bytes that sort in the same order as time.
+func timeKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.Unix())^(1<<63))
+	return k
+}
+
+func timeIndexKey(tk, key []byte) []byte {
+	k := make([]byte, 0, len(tk)+len(key))
+	return append(append(k, tk...), key...)
+}
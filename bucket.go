@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// units are the granularities of buckets.
+var units = []string{"day", "week", "month", "quarter"}
+
+// buckets divide time into calendar days, ISO weeks, months or quarters
+// in a location. Bucket 0 is the one that contains the first time.
+type buckets struct {
+	unit  string
+	loc   *time.Location
+	first time.Time
+}
+
+func newBuckets(unit string, loc *time.Location, first time.Time) buckets {
+	b := buckets{unit: unit, loc: loc}
+	b.first = b.truncate(first)
+	return b
+}
+
+// truncate returns the start of the bucket that contains t.
+func (b buckets) truncate(t time.Time) time.Time {
+	y, m, d := t.In(b.loc).Date()
+	switch b.unit {
+	case "week":
+		// ISO weeks start on Monday
+		d -= (int(t.In(b.loc).Weekday()) + 6) % 7
+	case "month":
+		d = 1
+	case "quarter":
+		m, d = m-(m-1)%3, 1
+	}
+	return startOfDay(y, m, d, b.loc)
+}
+
+// index returns the index of the bucket that contains t.
+func (b buckets) index(t time.Time) int {
+	y0, m0, _ := b.first.Date()
+	y, m, _ := b.truncate(t).Date()
+	months := (y-y0)*12 + int(m-m0)
+	switch b.unit {
+	case "month":
+		return months
+	case "quarter":
+		return months / 3
+	}
+	// count days by dates, which is immune to daylight saving time
+	days := int(civil(b.truncate(t)).Sub(civil(b.first)) / DayDuration)
+	if b.unit == "week" {
+		return days / 7
+	}
+	return days
+}
+
+func civil(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// start returns the start of the k-th bucket.
+func (b buckets) start(k int) time.Time {
+	y, m, d := b.first.Date()
+	switch b.unit {
+	case "day":
+		d += k
+	case "week":
+		d += 7 * k
+	case "month":
+		m += time.Month(k)
+	case "quarter":
+		m += time.Month(3 * k)
+	}
+	return startOfDay(y, m, d, b.loc)
+}
+
+// startOfDay returns the first time of the date in loc, which is later than
+// midnight if daylight saving time skips midnight, e.g., in Sao Paulo.
+func startOfDay(y int, m time.Month, d int, loc *time.Location) time.Time {
+	t := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	if t.Hour() != 0 && civil(t).Before(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)) {
+		// time.Date may take a skipped midnight in the zone after the
+		// gap, which is before the date, but the day starts when midnight
+		// comes in the zone before the gap, which is the zone of t
+		_, offset := t.Zone()
+		t = time.Date(y, m, d, 0, 0, 0, 0, time.FixedZone("", offset)).In(loc)
+	}
+	return t
+}
+
+// count returns the number of buckets that overlap [first, end).
+func (b buckets) count(end time.Time) int {
+	k := b.index(end)
+	if b.start(k).Before(end) {
+		k++
+	}
+	return k
+}
+
+// shift returns the buckets that start from the k-th bucket.
+func (b buckets) shift(k int) buckets {
+	b.first = b.start(k)
+	return b
+}
+
+// label returns the label of the k-th bucket, e.g., 2017-01-02 for a day,
+// 2017-W01 for a week, 2017-01 for a month and 2017-Q1 for a quarter.
+func (b buckets) label(k int) string {
+	t := b.start(k)
+	switch b.unit {
+	case "week":
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	case "month":
+		return t.Format("2006-01")
+	case "quarter":
+		return fmt.Sprintf("%d-Q%d", t.Year(), (t.Month()-1)/3+1)
+	}
+	return t.Format(DateFormat)
+}
+
+// title returns the unit capitalized for axis labels, where day is Date.
+func (b buckets) title() string {
+	if b.unit == "day" {
+		return "Date"
+	}
+	return strings.Title(b.unit)
+}
+
+// granularity is a flag.Value that selects the unit of buckets of time
+// series charts. A unit applies to all charts, and chart=unit applies to
+// the named chart only, e.g., solved_duration=quarter.
+type granularity struct {
+	all     string
+	byChart map[string]string
+	// loc is the location of calendar days.
+	loc *time.Location
+}
+
+func (g *granularity) String() string {
+	var ss []string
+	if g.all != "" {
+		ss = append(ss, g.all)
+	}
+	for name, unit := range g.byChart {
+		ss = append(ss, name+"="+unit)
+	}
+	return strings.Join(ss, ",")
+}
+
+func (g *granularity) Set(v string) error {
+	name, unit := "", v
+	if k := strings.Index(v, "="); k >= 0 {
+		name, unit = v[:k], v[k+1:]
+	}
+	valid := false
+	for _, u := range units {
+		valid = valid || u == unit
+	}
+	if !valid {
+		return fmt.Errorf("unknown interval %q, want one of %s", unit, strings.Join(units, ", "))
+	}
+	if name == "" {
+		g.all = unit
+		return nil
+	}
+	known := false
+	for _, tg := range timeGraphs {
+		known = known || tg.name == name
+	}
+	if !known {
+		var names []string
+		for _, tg := range timeGraphs {
+			names = append(names, tg.name)
+		}
+		return fmt.Errorf("unknown time series graph %q, want one of %s", name, strings.Join(names, ", "))
+	}
+	if g.byChart == nil {
+		g.byChart = make(map[string]string)
+	}
+	g.byChart[name] = unit
+	return nil
+}
+
+// with returns a copy of g that also applies values.
+func (g *granularity) with(values []string) (*granularity, error) {
+	c := &granularity{all: g.all, byChart: make(map[string]string), loc: g.loc}
+	for name, unit := range g.byChart {
+		c.byChart[name] = unit
+	}
+	for _, v := range values {
+		if err := c.Set(v); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// buckets returns the buckets of the named chart from first, in unit def
+// unless selected otherwise.
+func (g *granularity) buckets(name, def string, first time.Time) buckets {
+	unit := def
+	if g.all != "" {
+		unit = g.all
+	}
+	if u, ok := g.byChart[name]; ok {
+		unit = u
+	}
+	return newBuckets(unit, g.loc, first)
+}
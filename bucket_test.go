@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no time zone data of %s (%v)", name, err)
+	}
+	return loc
+}
+
+func TestBuckets(t *testing.T) {
+	la := loadLocation(t, "America/Los_Angeles")
+	at := func(y int, m time.Month, d, h, min int) time.Time { return time.Date(y, m, d, h, min, 0, 0, la) }
+	// daylight saving time starts on 2017-03-12 and ends on 2017-11-05
+	first := at(2016, time.December, 20, 10, 0)
+
+	tests := []struct {
+		unit  string
+		t     time.Time
+		index int
+		start time.Time
+	}{
+		{"day", first, 0, at(2016, time.December, 20, 0, 0)},
+		{"day", at(2016, time.December, 31, 23, 59), 11, at(2016, time.December, 31, 0, 0)},
+		{"day", at(2017, time.January, 1, 0, 0), 12, at(2017, time.January, 1, 0, 0)},
+		// 2017-01-01 05:00 UTC is still 2016-12-31 in Los Angeles
+		{"day", time.Date(2017, time.January, 1, 5, 0, 0, 0, time.UTC), 11, at(2016, time.December, 31, 0, 0)},
+		{"day", at(2017, time.March, 12, 23, 30), 82, at(2017, time.March, 12, 0, 0)},
+		{"day", at(2017, time.March, 13, 0, 30), 83, at(2017, time.March, 13, 0, 0)},
+		{"day", at(2017, time.November, 5, 23, 30), 320, at(2017, time.November, 5, 0, 0)},
+		{"day", at(2017, time.November, 6, 0, 30), 321, at(2017, time.November, 6, 0, 0)},
+
+		// ISO weeks start on Monday, and 2017-W01 starts on 2017-01-02
+		{"week", first, 0, at(2016, time.December, 19, 0, 0)},
+		{"week", at(2017, time.January, 1, 23, 59), 1, at(2016, time.December, 26, 0, 0)},
+		{"week", at(2017, time.January, 2, 0, 0), 2, at(2017, time.January, 2, 0, 0)},
+		{"week", at(2017, time.March, 12, 23, 59), 11, at(2017, time.March, 6, 0, 0)},
+		{"week", at(2017, time.March, 13, 0, 0), 12, at(2017, time.March, 13, 0, 0)},
+		{"week", at(2017, time.November, 6, 0, 0), 46, at(2017, time.November, 6, 0, 0)},
+
+		{"month", first, 0, at(2016, time.December, 1, 0, 0)},
+		{"month", at(2016, time.December, 31, 23, 59), 0, at(2016, time.December, 1, 0, 0)},
+		{"month", at(2017, time.January, 1, 0, 0), 1, at(2017, time.January, 1, 0, 0)},
+		{"month", at(2017, time.March, 31, 23, 59), 3, at(2017, time.March, 1, 0, 0)},
+		{"month", at(2017, time.November, 30, 23, 59), 11, at(2017, time.November, 1, 0, 0)},
+
+		{"quarter", first, 0, at(2016, time.October, 1, 0, 0)},
+		{"quarter", at(2017, time.January, 1, 0, 0), 1, at(2017, time.January, 1, 0, 0)},
+		{"quarter", at(2017, time.March, 31, 23, 59), 1, at(2017, time.January, 1, 0, 0)},
+		{"quarter", at(2017, time.April, 1, 0, 0), 2, at(2017, time.April, 1, 0, 0)},
+		{"quarter", at(2017, time.December, 31, 23, 59), 4, at(2017, time.October, 1, 0, 0)},
+	}
+	for _, tt := range tests {
+		b := newBuckets(tt.unit, la, first)
+		if got := b.index(tt.t); got != tt.index {
+			t.Errorf("%s: index(%v) = %d, want %d", tt.unit, tt.t, got, tt.index)
+		}
+		if got := b.start(tt.index); !got.Equal(tt.start) {
+			t.Errorf("%s: start(%d) = %v, want %v", tt.unit, tt.index, got, tt.start)
+		}
+		// a bucket ends where the next one starts
+		if got := b.count(tt.start); got != tt.index {
+			t.Errorf("%s: count(%v) = %d, want %d", tt.unit, tt.start, got, tt.index)
+		}
+		if got := b.count(tt.start.Add(time.Nanosecond)); got != tt.index+1 {
+			t.Errorf("%s: count(%v) = %d, want %d", tt.unit, tt.start.Add(time.Nanosecond), got, tt.index+1)
+		}
+	}
+}
+
+// TestBucketsContain checks that each hour over two years is in the bucket
+// of its index, also where midnight is skipped by daylight saving time, as
+// on 2016-10-16 in Sao Paulo.
+func TestBucketsContain(t *testing.T) {
+	for _, name := range []string{"UTC", "America/Los_Angeles", "America/Sao_Paulo", "Australia/Lord_Howe"} {
+		loc := loadLocation(t, name)
+		first := time.Date(2016, time.January, 1, 12, 0, 0, 0, loc)
+		for _, unit := range units {
+			b := newBuckets(unit, loc, first)
+			for h := 0; h < 2*365*24; h++ {
+				at := first.Add(time.Duration(h) * time.Hour)
+				k := b.index(at)
+				if at.Before(b.start(k)) || !at.Before(b.start(k+1)) {
+					t.Fatalf("%s %s: %v is out of bucket %d from %v to %v", name, unit, at, k, b.start(k), b.start(k+1))
+				}
+			}
+		}
+	}
+}
+
+func TestStartOfDay(t *testing.T) {
+	tests := []struct {
+		loc  string
+		date time.Time
+		want time.Time
+	}{
+		{"America/Los_Angeles", time.Date(2017, time.March, 12, 0, 0, 0, 0, time.UTC), time.Date(2017, time.March, 12, 8, 0, 0, 0, time.UTC)},
+		{"America/Los_Angeles", time.Date(2017, time.November, 5, 0, 0, 0, 0, time.UTC), time.Date(2017, time.November, 5, 7, 0, 0, 0, time.UTC)},
+		// midnight is skipped, and the day starts at 01:00 -02
+		{"America/Sao_Paulo", time.Date(2016, time.October, 16, 0, 0, 0, 0, time.UTC), time.Date(2016, time.October, 16, 3, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		loc := loadLocation(t, tt.loc)
+		y, m, d := tt.date.Date()
+		if got := startOfDay(y, m, d, loc); !got.Equal(tt.want) {
+			t.Errorf("startOfDay(%d, %d, %d, %s) = %v, want %v", y, m, d, tt.loc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/gonum/plot"
+	"github.com/gonum/plot/plotter"
+	"github.com/gonum/plot/plotutil"
+	"github.com/gonum/plot/vg"
+)
+
+// chart is the data of a graph. It is either a time series chart, whose
+// values are one for each bucket, or a bar chart, which has no buckets and
+// whose values are labeled by names. A chart of lines has no buckets either,
+// but draws its values labeled by names as lines. A stacked chart draws its
+// series as areas stacked from the first series at the bottom.
+type chart struct {
+	title  string
+	xLabel string
+	yLabel string
+
+	buckets *buckets
+	names   []string
+	lines   bool
+	stacked bool
+
+	series []series
+	// markers label some values of a time series chart, if any.
+	markers []marker
+}
+
+// series is a line, or bars, in a chart. Unnamed series has no legend.
+type series struct {
+	name   string
+	values seqFloats
+}
+
+// newTimeChart returns a chart of the buckets in the period, where b are
+// the buckets of the whole history as period.seqInts takes. The x label
+// is the unit of buckets followed by xSuffix, if any.
+func newTimeChart(per *period, b buckets, title, xSuffix, yLabel string) *chart {
+	xUnit := b.title()
+	if xSuffix != "" {
+		xUnit += " " + xSuffix
+	}
+	b = b.shift(b.index(per.start))
+	return &chart{
+		title:   title,
+		xLabel:  fmt.Sprintf("%s from %s to %s", xUnit, per.start.In(b.loc).Format(DateFormat), per.end.In(b.loc).Format(DateFormat)),
+		yLabel:  yLabel,
+		buckets: &b,
+	}
+}
+
+func (c *chart) add(name string, values seqFloats) {
+	c.series = append(c.series, series{name: name, values: values})
+}
+
+func (c *chart) isTimeSeries() bool { return c.buckets != nil }
+
+// date returns the date that the k-th bucket of a time series chart
+// starts on.
+func (c *chart) date(k int) string {
+	return c.buckets.start(k).Format(DateFormat)
+}
+
+// label returns the label of the k-th values of the series.
+func (c *chart) label(k int) string {
+	if c.isTimeSeries() {
+		return c.buckets.label(k)
+	}
+	return c.names[k]
+}
+
+func (c *chart) plot() (*plot.Plot, error) {
+	p, err := plot.New()
+	if err != nil {
+		return nil, err
+	}
+
+	p.Title.Text = c.title
+	p.X.Label.Text = c.xLabel
+	p.Y.Label.Text = c.yLabel
+	if !c.isTimeSeries() && !c.lines {
+		if len(c.names) > 0 {
+			p.NominalX(c.names...)
+			bars, err := plotter.NewBarChart(plotter.Values(c.series[0].values), vg.Points(20))
+			if err != nil {
+				return nil, err
+			}
+			bars.LineStyle.Width = vg.Length(0)
+			p.Add(bars)
+		}
+		return p, nil
+	}
+
+	if c.stacked {
+		if err := c.addStackedAreas(p); err != nil {
+			return nil, err
+		}
+	} else if err := c.addLines(p); err != nil {
+		return nil, err
+	}
+	p.X.Tick.Marker = &labelTicker{
+		Ticker: p.X.Tick.Marker,
+		label:  c.label,
+		n:      c.len(),
+	}
+	if len(c.markers) > 0 {
+		font, err := vg.MakeFont(plot.DefaultFont, vg.Points(8))
+		if err != nil {
+			return nil, err
+		}
+		p.Add(markerPlotter{c.markers, font})
+	}
+	return p, nil
+}
+
+func (c *chart) addLines(p *plot.Plot) error {
+	var lines []interface{}
+	for _, s := range c.series {
+		if s.name != "" {
+			lines = append(lines, s.name)
+		}
+		lines = append(lines, s.values)
+	}
+	return plotutil.AddLines(p, lines...)
+}
+
+// addStackedAreas adds the series as areas, each on top of the ones before
+// it, which are added from the top as they paint over each other.
+func (c *chart) addStackedAreas(p *plot.Plot) error {
+	xs := make(plotter.Values, c.len())
+	for k := range xs {
+		xs[k] = float64(k)
+	}
+	tops := make([]plotter.Values, len(c.series))
+	for n, s := range c.series {
+		tops[n] = make(plotter.Values, c.len())
+		for k, v := range s.values {
+			tops[n][k] = v
+			if n > 0 {
+				tops[n][k] += tops[n-1][k]
+			}
+		}
+	}
+	var areas []interface{}
+	for n := len(c.series) - 1; n >= 0; n-- {
+		areas = append(areas, c.seriesName(c.series[n]), tops[n])
+	}
+	if err := plotutil.AddStackedAreaPlots(p, xs, areas...); err != nil {
+		return err
+	}
+	// areas stand on zero
+	p.Y.Min = 0
+	return nil
+}
+
+// save saves the graph of the chart to an image file, whose format is
+// determined by the extension.
+func (c *chart) save(filename string) error {
+	p, err := c.plot()
+	if err != nil {
+		return err
+	}
+	return p.Save(graphWidth, graphHeight, filename)
+}
+
+// writeImage writes the graph of the chart in format png, svg, pdf or eps.
+func (c *chart) writeImage(w io.Writer, format string) error {
+	p, err := c.plot()
+	if err != nil {
+		return err
+	}
+	wt, err := p.WriterTo(graphWidth, graphHeight, format)
+	if err != nil {
+		return err
+	}
+	_, err = wt.WriteTo(w)
+	return err
+}
+
+// export writes the values of the chart into a file in format csv or json.
+func (c *chart) export(filename, format string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.writeData(f, format); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeData writes the values of the chart in format csv or json.
+func (c *chart) writeData(w io.Writer, format string) error {
+	switch format {
+	case "csv":
+		return c.writeCSV(w)
+	case "json":
+		return json.NewEncoder(w).Encode(c)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// writeCSV writes a header row and then a row for each date, or name,
+// with the values of all series.
+func (c *chart) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"date"}
+	if !c.isTimeSeries() {
+		header[0] = "name"
+	}
+	// buckets longer than a day also have their labels, e.g., 2017-W01,
+	// next to the dates they start on
+	labeled := c.isTimeSeries() && c.buckets.unit != "day"
+	if labeled {
+		header = append(header, c.buckets.unit)
+	}
+	for _, s := range c.series {
+		header = append(header, c.seriesName(s))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for k := 0; k < c.len(); k++ {
+		row := []string{c.label(k)}
+		if c.isTimeSeries() {
+			row[0] = c.date(k)
+		}
+		if labeled {
+			row = append(row, c.label(k))
+		}
+		for _, s := range c.series {
+			row = append(row, strconv.FormatFloat(s.values[k], 'g', -1, 64))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type chartJSON struct {
+	Title  string   `json:"title"`
+	XLabel string   `json:"x_label,omitempty"`
+	YLabel string   `json:"y_label"`
+	Dates  []string `json:"dates,omitempty"`
+	// Labels are the labels of buckets longer than a day, e.g., 2017-W01.
+	Labels  []string     `json:"labels,omitempty"`
+	Names   []string     `json:"names,omitempty"`
+	Lines   bool         `json:"lines,omitempty"`
+	Stacked bool         `json:"stacked,omitempty"`
+	Series  []seriesJSON `json:"series"`
+	Markers []markerJSON `json:"markers,omitempty"`
+}
+
+// markerJSON labels the values at index of series.
+type markerJSON struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+}
+
+type seriesJSON struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+func (c *chart) MarshalJSON() ([]byte, error) {
+	v := chartJSON{Title: c.title, XLabel: c.xLabel, YLabel: c.yLabel, Names: c.names, Lines: c.lines, Stacked: c.stacked}
+	// charts of downloads have no series before the second snapshot
+	v.Series = []seriesJSON{}
+	if c.isTimeSeries() {
+		v.Dates = make([]string, c.len())
+		for k := range v.Dates {
+			v.Dates[k] = c.date(k)
+		}
+		if c.buckets.unit != "day" {
+			v.Labels = make([]string, c.len())
+			for k := range v.Labels {
+				v.Labels[k] = c.label(k)
+			}
+		}
+	}
+	for _, s := range c.series {
+		v.Series = append(v.Series, seriesJSON{Name: c.seriesName(s), Values: s.values})
+	}
+	for _, m := range c.markers {
+		v.Markers = append(v.Markers, markerJSON{m.k, m.name})
+	}
+	return json.Marshal(v)
+}
+
+// len returns the number of values in each series.
+func (c *chart) len() int {
+	if len(c.series) == 0 {
+		return 0
+	}
+	return len(c.series[0].values)
+}
+
+// seriesName returns the name of the series, or the y label of the chart
+// for unnamed series.
+func (c *chart) seriesName(s series) string {
+	if s.name == "" {
+		return c.yLabel
+	}
+	return s.name
+}
+
+// labelTicker labels the major ticks of the x axis, which are indexes of
+// the n values of series, by the labels of the chart.
+type labelTicker struct {
+	plot.Ticker
+	label func(k int) string
+	n     int
+}
+
+func (lt *labelTicker) Ticks(min, max float64) []plot.Tick {
+	ts := lt.Ticker.Ticks(min, max)
+	for i, t := range ts {
+		if k := int(t.Value); t.Label != "" {
+			t.Label = ""
+			if k >= 0 && k < lt.n {
+				t.Label = lt.label(k)
+			}
+		}
+		ts[i] = t
+	}
+	return ts
+}
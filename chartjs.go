@@ -0,0 +1,240 @@
+package main
+
+// chartScript renders interactive charts in the report without any
+// external dependency.
+const chartScript = `(function() {
+	var svgNS = "http://www.w3.org/2000/svg";
+	var colors = ["#e41a1c", "#4daf4a", "#377eb8", "#984ea3", "#ff7f00", "#a65628", "#f781bf", "#999999"];
+
+	function add(parent, tag, attrs, text) {
+		var e = document.createElementNS(svgNS, tag);
+		for (var k in attrs) {
+			e.setAttribute(k, attrs[k]);
+		}
+		if (text !== undefined) {
+			e.textContent = text;
+		}
+		parent.appendChild(e);
+		return e;
+	}
+
+	function escape(s) {
+		return String(s).replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+	}
+
+	function format(v) {
+		return v === Math.round(v) ? String(v) : v.toFixed(2);
+	}
+
+	// ticks returns about 5 round values from 0 to at least max.
+	function ticks(max) {
+		var raw = max / 5;
+		var mag = Math.pow(10, Math.floor(Math.log(raw) / Math.LN10));
+		var r = raw / mag;
+		var step = (r <= 1 ? 1 : r <= 2 ? 2 : r <= 5 ? 5 : 10) * mag;
+		var ts = [];
+		for (var k = 0; ts.length === 0 || ts[ts.length - 1] < max; k++) {
+			ts.push(k * step);
+		}
+		return ts;
+	}
+
+	// renderChart draws the chart data, as exported by -export json, into
+	// container. Hovering shows the values, dragging zooms into a date range,
+	// double clicking resets the zoom, and clicking a legend toggles its series.
+	window.renderChart = function(container, data) {
+		var labels = data.labels || data.dates || data.names || [];
+		var isBar = !data.dates && !data.lines;
+		var stacked = !!data.stacked;
+		var hidden = {};
+		var lo = 0, hi = labels.length - 1;
+		var W = 600, H = 340, L = 60, R = 15, T = 15, B = isBar ? 70 : 45;
+		var pw = W - L - R, ph = H - T - B;
+		var dragFrom = null;
+
+		container.style.position = "relative";
+		var svg = add(container, "svg", {viewBox: "0 0 " + W + " " + H, width: W, height: H});
+		svg.style.maxWidth = "100%";
+		svg.style.height = "auto";
+		var tip = document.createElement("div");
+		tip.className = "tip";
+		container.appendChild(tip);
+		var legend = document.createElement("div");
+		legend.className = "legend";
+		container.appendChild(legend);
+
+		function slots() { return isBar ? hi - lo + 1 : Math.max(hi - lo, 1); }
+		function x(i) { return L + (i - lo + (isBar ? 0.5 : 0)) / slots() * pw; }
+		function index(px) {
+			var i = lo + (isBar ? Math.floor((px - L) / pw * slots()) : Math.round((px - L) / pw * slots()));
+			return Math.min(Math.max(i, lo), hi);
+		}
+
+		// tops returns the top of each series, which is its values, or the
+		// sums of the values of the visible series up to it if stacked.
+		function tops() {
+			var sum = labels.map(function() { return 0; });
+			return data.series.map(function(s, j) {
+				if (!stacked) {
+					return s.values;
+				}
+				if (!hidden[j]) {
+					sum = sum.map(function(v, i) { return v + s.values[i]; });
+				}
+				return sum;
+			});
+		}
+
+		var hover, selection, y;
+		function draw() {
+			while (svg.firstChild) {
+				svg.removeChild(svg.firstChild);
+			}
+			var max = 0, ts = tops();
+			data.series.forEach(function(s, j) {
+				for (var i = lo; !hidden[j] && i <= hi; i++) {
+					max = Math.max(max, ts[j][i]);
+				}
+			});
+			var yts = ticks(max > 0 ? max : 1);
+			var top = yts[yts.length - 1];
+			y = function(v) { return T + ph - v / top * ph; };
+
+			yts.forEach(function(t) {
+				add(svg, "line", {x1: L, x2: W - R, y1: y(t), y2: y(t), stroke: "#eee"});
+				add(svg, "text", {x: L - 5, y: y(t) + 4, "text-anchor": "end", "font-size": 11}, format(t));
+			});
+			add(svg, "line", {x1: L, x2: W - R, y1: T + ph, y2: T + ph, stroke: "#333"});
+			add(svg, "line", {x1: L, x2: L, y1: T, y2: T + ph, stroke: "#333"});
+			var n = hi - lo + 1, step = isBar ? 1 : Math.max(1, Math.ceil(n / 5));
+			for (var i = lo; i <= hi && labels.length > 0; i += step) {
+				var attrs = {x: x(i), y: T + ph + 15, "text-anchor": "middle", "font-size": 11};
+				if (isBar) {
+					attrs["text-anchor"] = "end";
+					attrs.transform = "rotate(-30 " + x(i) + " " + (T + ph + 15) + ")";
+				}
+				add(svg, "text", attrs, labels[i]);
+			}
+			add(svg, "text", {x: L + pw / 2, y: H - 5, "text-anchor": "middle", "font-size": 12}, data.x_label || "");
+			add(svg, "text", {x: 12, y: T + ph / 2, "text-anchor": "middle", "font-size": 12,
+				transform: "rotate(-90 12 " + (T + ph / 2) + ")"}, data.y_label || "");
+
+			data.series.forEach(function(s, j) {
+				if (hidden[j]) {
+					return;
+				}
+				var color = colors[j % colors.length];
+				if (isBar) {
+					var w = pw / slots() * 0.6;
+					for (var i = lo; i <= hi; i++) {
+						add(svg, "rect", {x: x(i) - w / 2, y: y(s.values[i]), width: w, height: T + ph - y(s.values[i]), fill: color});
+					}
+					return;
+				}
+				var d = "";
+				for (var i = lo; i <= hi; i++) {
+					d += (i === lo ? "M" : "L") + x(i).toFixed(1) + " " + y(ts[j][i]).toFixed(1);
+				}
+				if (stacked) {
+					// close the area down to the top of the series below
+					for (var i = hi; i >= lo; i--) {
+						d += "L" + x(i).toFixed(1) + " " + y(j > 0 ? ts[j - 1][i] : 0).toFixed(1);
+					}
+					add(svg, "path", {d: d + "Z", fill: color, "fill-opacity": 0.7, stroke: color, "stroke-width": 1});
+					return;
+				}
+				add(svg, "path", {d: d, fill: "none", stroke: color, "stroke-width": 1.5});
+			});
+
+			(data.markers || []).forEach(function(m) {
+				if (m.index < lo || m.index > hi) {
+					return;
+				}
+				add(svg, "line", {x1: x(m.index), x2: x(m.index), y1: T, y2: T + ph, stroke: "#888", "stroke-dasharray": "3 3"});
+				add(svg, "text", {x: x(m.index) - 3, y: T, "text-anchor": "end", "font-size": 10, fill: "#666",
+					transform: "rotate(-90 " + (x(m.index) - 3) + " " + T + ")"}, m.name);
+			});
+
+			hover = add(svg, "line", {y1: T, y2: T + ph, stroke: "#999", visibility: "hidden"});
+			selection = add(svg, "rect", {y: T, height: ph, fill: "rgba(55, 126, 184, 0.2)", visibility: "hidden"});
+		}
+
+		function position(ev) {
+			var r = svg.getBoundingClientRect();
+			return {x: (ev.clientX - r.left) * W / r.width, y: (ev.clientY - r.top) * H / r.height};
+		}
+
+		svg.addEventListener("mousemove", function(ev) {
+			var p = position(ev);
+			if (labels.length === 0 || p.x < L || p.x > W - R) {
+				tip.style.display = "none";
+				hover.setAttribute("visibility", "hidden");
+				return;
+			}
+			var i = index(p.x);
+			hover.setAttribute("x1", x(i));
+			hover.setAttribute("x2", x(i));
+			hover.setAttribute("visibility", "visible");
+			if (dragFrom !== null) {
+				selection.setAttribute("x", Math.min(x(dragFrom), x(i)));
+				selection.setAttribute("width", Math.abs(x(i) - x(dragFrom)));
+				selection.setAttribute("visibility", "visible");
+			}
+			var html = "<b>" + escape(labels[i]) + "</b>";
+			data.series.forEach(function(s, j) {
+				if (!hidden[j]) {
+					html += "<br><span style=\"color:" + colors[j % colors.length] + "\">■</span> " +
+						escape(s.name) + ": " + format(s.values[i]);
+				}
+			});
+			tip.innerHTML = html;
+			tip.style.display = "block";
+			var r = svg.getBoundingClientRect();
+			tip.style.left = (x(i) * r.width / W + 10) + "px";
+			tip.style.top = (p.y * r.height / H) + "px";
+		});
+		svg.addEventListener("mouseleave", function() {
+			tip.style.display = "none";
+			hover.setAttribute("visibility", "hidden");
+			selection.setAttribute("visibility", "hidden");
+			dragFrom = null;
+		});
+		svg.addEventListener("mousedown", function(ev) {
+			if (!isBar && labels.length > 0) {
+				dragFrom = index(position(ev).x);
+				ev.preventDefault();
+			}
+		});
+		svg.addEventListener("mouseup", function(ev) {
+			if (dragFrom === null) {
+				return;
+			}
+			var i = index(position(ev).x);
+			if (Math.abs(i - dragFrom) >= 2) {
+				lo = Math.min(i, dragFrom);
+				hi = Math.max(i, dragFrom);
+			}
+			dragFrom = null;
+			draw();
+		});
+		svg.addEventListener("dblclick", function() {
+			lo = 0;
+			hi = labels.length - 1;
+			draw();
+		});
+
+		data.series.forEach(function(s, j) {
+			var item = document.createElement("span");
+			item.innerHTML = "<span style=\"color:" + colors[j % colors.length] + "\">■</span> ";
+			item.appendChild(document.createTextNode(s.name));
+			item.addEventListener("click", function() {
+				hidden[j] = !hidden[j];
+				item.className = hidden[j] ? "off" : "";
+				draw();
+			});
+			legend.appendChild(item);
+		});
+		draw();
+	};
+})();
+`
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// LoadComments syncs the comments of all issues in the repo, and groups
+// them by issue number in time order.
+func (c *repoClient) LoadComments() error {
+	syncedAt, err := c.syncTime("comments")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	fetched, err := c.fetchComments(syncedAt)
+	if err != nil {
+		return err
+	}
+	rs := make([]record, len(fetched))
+	for k, cm := range fetched {
+		rs[k] = record{Key: strconv.Itoa(cm.GetID()), Time: cm.GetCreatedAt(), Value: cm}
+	}
+	if err := c.put("comments", rs, now); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "stored %d updated comments\n", len(fetched))
+
+	c.comments = make(map[int][]*github.IssueComment)
+	err = c.walkStored("comments", func(data []byte) error {
+		cm := &github.IssueComment{}
+		if err := json.Unmarshal(data, cm); err != nil {
+			return err
+		}
+		n := numberOfURL(cm.GetIssueURL())
+		c.comments[n] = append(c.comments[n], cm)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, cms := range c.comments {
+		sort.SliceStable(cms, func(i, j int) bool { return cms[i].GetCreatedAt().Before(cms[j].GetCreatedAt()) })
+	}
+	return nil
+}
+
+// IssueComments returns the comments of the issue in time order, leaving
+// out comments by dropped accounts.
+func (c *repoClient) IssueComments(i github.Issue) []*github.IssueComment {
+	cms := c.part(i).comments[i.GetNumber()]
+	if c.accounts == nil {
+		return cms
+	}
+	var kept []*github.IssueComment
+	for _, cm := range cms {
+		if c.accounts.Match(cm.User) {
+			kept = append(kept, cm)
+		}
+	}
+	return kept
+}
+
+// fetchComments lists comments of all issues in the repo that are updated
+// at or after since. A zero since lists all comments.
+func (c *repoClient) fetchComments(since time.Time) ([]*github.IssueComment, error) {
+	opt := &github.IssueListCommentsOptions{
+		Since: since,
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	var comments []*github.IssueComment
+	for {
+		// number 0 lists comments on all issues
+		cms, resp, err := c.client.Issues.ListComments(context.TODO(), c.owner, c.repo, 0, opt)
+		if err != nil {
+			return nil, fmt.Errorf("listing comments (%v)", err)
+		}
+		comments = append(comments, cms...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.ListOptions.Page = resp.NextPage
+		fmt.Fprintf(os.Stderr, "list %d comments...\n", len(comments))
+	}
+	return comments, nil
+}
+
+// numberOfURL returns the number at the end of an issue or pull request
+// API URL.
+func numberOfURL(url string) int {
+	n, _ := strconv.Atoi(url[strings.LastIndex(url, "/")+1:])
+	return n
+}
+
+// LoadMembers loads the logins of the members of the owner organization,
+// or the collaborators of the repo, as selected by kind.
+func (c *repoClient) LoadMembers(kind string) (map[string]bool, error) {
+	syncedAt, err := c.syncTime(kind)
+	if err != nil {
+		return nil, err
+	}
+	// membership changes rarely, so refetch it daily
+	if time.Now().Sub(syncedAt) >= DayDuration {
+		now := time.Now()
+		syncedAt = now
+		fetched, err := c.fetchMembers(kind)
+		if err != nil {
+			return nil, err
+		}
+		rs := make([]record, len(fetched))
+		for k, u := range fetched {
+			rs[k] = record{Key: u.GetLogin(), Time: now, Value: u.GetLogin()}
+		}
+		if err := c.put(kind, rs, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// the logins of the last fetch are stored at its sync time, and older
+	// ones are of users who have left since
+	logins := make(map[string]bool)
+	err = c.store.Walk(c.name(), kind, syncedAt, time.Time{}, func(data []byte) error {
+		var login string
+		if err := json.Unmarshal(data, &login); err != nil {
+			return err
+		}
+		logins[login] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading stored %s (%v)", kind, err)
+	}
+	return logins, nil
+}
+
+func (c *repoClient) fetchMembers(kind string) ([]*github.User, error) {
+	opt := &github.ListMembersOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	var users []*github.User
+	for {
+		var (
+			us   []*github.User
+			resp *github.Response
+			err  error
+		)
+		switch kind {
+		case "members":
+			us, resp, err = c.client.Organizations.ListMembers(context.TODO(), c.owner, opt)
+		case "collaborators":
+			us, resp, err = c.client.Repositories.ListCollaborators(context.TODO(), c.owner, c.repo, &opt.ListOptions)
+		default:
+			return nil, fmt.Errorf("unknown kind of members %q", kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing %s (%v)", kind, err)
+		}
+		users = append(users, us...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.ListOptions.Page = resp.NextPage
+	}
+	return users, nil
+}
+
+func isBot(u *github.User) bool {
+	return u.GetType() == "Bot" || strings.HasSuffix(u.GetLogin(), "[bot]")
+}
+
+// responderFilter selects the users whose comments count as responses.
+type responderFilter struct {
+	ignoreBots bool
+	// logins are the users allowed to respond, or nil to allow everyone.
+	logins map[string]bool
+}
+
+func (f *responderFilter) Match(u *github.User) bool {
+	if f.ignoreBots && isBot(u) {
+		return false
+	}
+	return f.logins == nil || f.logins[u.GetLogin()]
+}
+
+// FirstResponse returns the first comment on the issue that is made by
+// someone other than its author and counts as a response, or nil if
+// there is none.
+func (c *repoClient) FirstResponse(i github.Issue) *github.IssueComment {
+	f := c.part(i).responders
+	for _, cm := range c.IssueComments(i) {
+		if cm.User.GetLogin() == i.User.GetLogin() {
+			continue
+		}
+		if f == nil || f.Match(cm.User) {
+			return cm
+		}
+	}
+	return nil
+}
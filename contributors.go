@@ -0,0 +1,146 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// contributorFilter selects the authors of issues and pull requests that
+// count as contributors.
+type contributorFilter struct {
+	// members are the users to exclude, or nil to exclude none.
+	members map[string]bool
+}
+
+func (f *contributorFilter) Match(u *github.User) bool {
+	return !f.members[u.GetLogin()]
+}
+
+// authorActivity is when a contributor opened issues and pull requests, in
+// time order.
+type authorActivity struct {
+	issues, prs []time.Time
+}
+
+// first returns the time of the first issue or pull request.
+func (a *authorActivity) first() time.Time {
+	if len(a.issues) == 0 {
+		return a.prs[0]
+	}
+	if len(a.prs) == 0 || a.issues[0].Before(a.prs[0]) {
+		return a.issues[0]
+	}
+	return a.prs[0]
+}
+
+// contributorActivity returns the activity of each contributor by login,
+// counting the issues and pull requests opened before end.
+func contributorActivity(rc *repoClient, end time.Time) map[string]*authorActivity {
+	acts := make(map[string]*authorActivity)
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if i.User == nil || !i.CreatedAt.Before(end) {
+			return
+		}
+		if f := rc.part(i).contributors; f != nil && !f.Match(i.User) {
+			return
+		}
+		a := acts[i.User.GetLogin()]
+		if a == nil {
+			a = &authorActivity{}
+			acts[i.User.GetLogin()] = a
+		}
+		if isPullRequest {
+			a.prs = append(a.prs, *i.CreatedAt)
+		} else {
+			a.issues = append(a.issues, *i.CreatedAt)
+		}
+	})
+	for _, a := range acts {
+		sort.Slice(a.issues, func(i, j int) bool { return a.issues[i].Before(a.issues[j]) })
+		sort.Slice(a.prs, func(i, j int) bool { return a.prs[i].Before(a.prs[j]) })
+	}
+	return acts
+}
+
+func activeAuthorsChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
+
+	l := b.index(end) + 1
+	issues := make([]int, l)
+	prs := make([]int, l)
+	all := make([]int, l)
+	// count distinct buckets of the times of each author
+	count := func(counts []int, ts ...[]time.Time) {
+		seen := make(map[int]bool)
+		for _, t := range ts {
+			for _, at := range t {
+				if k := b.index(at); !seen[k] {
+					seen[k] = true
+					counts[k]++
+				}
+			}
+		}
+	}
+	for _, a := range contributorActivity(rc, end) {
+		count(issues, a.issues)
+		count(prs, a.prs)
+		count(all, a.issues, a.prs)
+	}
+
+	c := newTimeChart(per, b, "Active Authors", "", "Count")
+	c.add("issue or PR authors", per.seqInts(all, b).floats())
+	c.add("issue authors", per.seqInts(issues, b).floats())
+	c.add("PR authors", per.seqInts(prs, b).floats())
+	return c
+}
+
+func newContributorsChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
+
+	l := b.index(end) + 1
+	firsts := make([]int, l)
+	firstPRs := make([]int, l)
+	for _, a := range contributorActivity(rc, end) {
+		firsts[b.index(a.first())]++
+		if len(a.prs) > 0 {
+			firstPRs[b.index(a.prs[0])]++
+		}
+	}
+
+	c := newTimeChart(per, b, "First-time Contributors", "", "Count")
+	c.add("first issue or PR", per.seqInts(firsts, b).floats())
+	c.add("first PR", per.seqInts(firstPRs, b).floats())
+	return c
+}
+
+func pullRequestRetentionChart(rc *repoClient, per *period, b buckets) *chart {
+	// second pull requests count up to the end of the period
+	acts := contributorActivity(rc, per.end)
+
+	l := b.index(rc.EndTime()) + 1
+	firsts := make([]int, l)
+	returned := make([]int, l)
+	for _, a := range acts {
+		if len(a.prs) == 0 {
+			continue
+		}
+		k := b.index(a.prs[0])
+		firsts[k]++
+		if len(a.prs) > 1 {
+			returned[k]++
+		}
+	}
+
+	fractions := make([]float64, l)
+	for k := range firsts {
+		if firsts[k] != 0 {
+			fractions[k] = float64(returned[k]) / float64(firsts[k])
+		}
+	}
+
+	c := newTimeChart(per, b, "Retention of First-time PR Authors", "of first PR", "Fraction")
+	c.add("", per.seqFloats(fractions, b))
+	return c
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// downloadSnapshot is the download count of a release asset at a time.
+// GitHub keeps no history of download counts, so snapshots are recorded
+// whenever releases are fetched, and are keyed by date.
+type downloadSnapshot struct {
+	Time    time.Time `json:"time"`
+	AssetID int       `json:"asset_id"`
+	Asset   string    `json:"asset"`
+	Release string    `json:"release"`
+	Count   int       `json:"count"`
+}
+
+// putDownloads records the download counts of the assets of the releases
+// at now. Snapshots of the same calendar day replace each other.
+func (c *repoClient) putDownloads(releases []*github.RepositoryRelease, now time.Time) error {
+	var rs []record
+	for _, r := range releases {
+		for _, a := range r.Assets {
+			s := downloadSnapshot{now, a.GetID(), a.GetName(), r.GetTagName(), a.GetDownloadCount()}
+			key := now.In(c.loc).Format(DateFormat) + "/" + strconv.Itoa(a.GetID())
+			rs = append(rs, record{Key: key, Time: now, Value: s})
+		}
+	}
+	if err := c.put("downloads", rs, now); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "stored %d download counts\n", len(rs))
+	return nil
+}
+
+func (c *repoClient) loadDownloads() error {
+	c.downloads = nil
+	return c.walkStored("downloads", func(data []byte) error {
+		var s downloadSnapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		c.downloads = append(c.downloads, s)
+		return nil
+	})
+}
+
+// walkDownloads calls f with each snapshot after the first of its asset,
+// and the downloads since the snapshot before it.
+func (c *repoClient) walkDownloads(f func(s downloadSnapshot, n int)) {
+	last := make(map[int]int)
+	for _, s := range c.downloads {
+		prev, ok := last[s.AssetID]
+		last[s.AssetID] = s.Count
+		// counts only grow, unless an asset is replaced
+		if ok && s.Count > prev {
+			f(s, s.Count-prev)
+		}
+	}
+}
+
+// platforms and archs are the parts of asset names that tell the type of
+// assets, where longer ones go first to match before their prefixes.
+var (
+	platforms = []string{"linux", "darwin", "windows", "freebsd", "openbsd", "netbsd"}
+	archs     = []string{"amd64", "x86_64", "arm64", "aarch64", "armv7", "armv6", "arm", "386", "ppc64le", "s390x"}
+)
+
+// assetType returns the platform and architecture in the name of the asset
+// of the release, e.g., linux-amd64, or else the name without the tag.
+func assetType(name, tag string) string {
+	lower := strings.ToLower(name)
+	var parts []string
+	for _, ps := range [][]string{platforms, archs} {
+		for _, p := range ps {
+			if strings.Contains(lower, p) {
+				parts = append(parts, p)
+				break
+			}
+		}
+	}
+	if len(parts) > 0 {
+		return strings.Join(parts, "-")
+	}
+	for _, t := range []string{tag, strings.TrimPrefix(tag, "v")} {
+		if t != "" {
+			name = strings.Replace(name, t, "", -1)
+		}
+	}
+	// e.g., etcd-src.zip from etcd-v3.1.0-src.zip
+	for _, sep := range []string{"--", "__", ".."} {
+		name = strings.Replace(name, sep, sep[:1], -1)
+	}
+	return strings.Trim(name, "-_.")
+}
+
+// downloadsChart draws the downloads in each of buckets b of the top 5
+// keys of snapshots, and of the other keys together. Downloads between two
+// snapshots count in the bucket of the later one.
+func downloadsChart(rc *repoClient, per *period, b buckets, title string, key func(s downloadSnapshot) string) *chart {
+	end := rc.EndTime()
+
+	l := b.index(end) + 1
+	counts := make(map[string][]int)
+	totals := make(map[string]int)
+	rc.walkDownloads(func(s downloadSnapshot, n int) {
+		i := b.index(s.Time)
+		if i < 0 || i >= l {
+			return
+		}
+		k := key(s)
+		if counts[k] == nil {
+			counts[k] = make([]int, l)
+		}
+		counts[k][i] += n
+		if inPeriod(s.Time, per.start, per.end) {
+			totals[k] += n
+		}
+	})
+	var keys []string
+	for k := range totals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if totals[keys[i]] != totals[keys[j]] {
+			return totals[keys[i]] > totals[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	c := newTimeChart(per, b, title, "", "Downloads")
+	other := make([]int, l)
+	for n, k := range keys {
+		if n < 5 {
+			c.add(k, per.seqInts(counts[k], b).floats())
+			continue
+		}
+		for i, v := range counts[k] {
+			other[i] += v
+		}
+	}
+	if len(keys) > 5 {
+		c.add("other", per.seqInts(other, b).floats())
+	}
+	return c
+}
+
+func releaseDownloadsChart(rc *repoClient, per *period, b buckets) *chart {
+	return downloadsChart(rc, per, b, "Downloads by Release", func(s downloadSnapshot) string { return s.Release })
+}
+
+func assetDownloadsChart(rc *repoClient, per *period, b buckets) *chart {
+	return downloadsChart(rc, per, b, "Downloads by Asset Type", func(s downloadSnapshot) string { return assetType(s.Asset, s.Release) })
+}
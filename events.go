@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// LoadIssueEvents syncs the events of all issues in the repo, and groups
+// them by issue number in time order.
+func (c *repoClient) LoadIssueEvents() error {
+	syncedAt, err := c.syncTime("issue_events")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	fetched, err := c.fetchIssueEvents(syncedAt)
+	if err != nil {
+		return err
+	}
+	rs := make([]record, len(fetched))
+	for k, e := range fetched {
+		rs[k] = record{Key: strconv.Itoa(e.GetID()), Time: e.GetCreatedAt(), Value: e}
+	}
+	if err := c.put("issue_events", rs, now); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "stored %d new issue events\n", len(fetched))
+
+	c.events = make(map[int][]*github.IssueEvent)
+	err = c.walkStored("issue_events", func(data []byte) error {
+		e := &github.IssueEvent{}
+		if err := json.Unmarshal(data, e); err != nil {
+			return err
+		}
+		n := e.Issue.GetNumber()
+		c.events[n] = append(c.events[n], e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, es := range c.events {
+		sort.SliceStable(es, func(i, j int) bool { return es[i].GetCreatedAt().Before(es[j].GetCreatedAt()) })
+	}
+	return nil
+}
+
+// IssueEvents returns the events of the issue in time order.
+func (c *repoClient) IssueEvents(i github.Issue) []*github.IssueEvent {
+	return c.part(i).events[i.GetNumber()]
+}
+
+// fetchIssueEvents lists events of all issues in the repo that are created
+// at or after since. The issue of each event is trimmed to its number.
+func (c *repoClient) fetchIssueEvents(since time.Time) ([]*github.IssueEvent, error) {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+	var events []*github.IssueEvent
+	for {
+		es, resp, err := c.client.Issues.ListRepositoryEvents(context.TODO(), c.owner, c.repo, opt)
+		if err != nil {
+			return nil, fmt.Errorf("listing issue events (%v)", err)
+		}
+		// events are listed from the newest to the oldest
+		done := false
+		for _, e := range es {
+			if e.GetCreatedAt().Before(since) {
+				done = true
+				break
+			}
+			e.Issue = &github.Issue{Number: github.Int(e.Issue.GetNumber())}
+			events = append(events, e)
+		}
+		if done || resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+		fmt.Fprintf(os.Stderr, "list %d issue events...\n", len(events))
+	}
+	return events, nil
+}
+
+type interval struct {
+	start, end time.Time
+}
+
+// OpenIntervals returns the intervals in which the issue was open, built
+// from its close and reopen events. An issue that is still open is
+// regarded as open until end.
+func (c *repoClient) OpenIntervals(i github.Issue, end time.Time) []interval {
+	var ivs []interval
+	open, from := true, i.GetCreatedAt()
+	for _, e := range c.IssueEvents(i) {
+		switch e.GetEvent() {
+		case "closed":
+			if open {
+				ivs = append(ivs, interval{from, e.GetCreatedAt()})
+				open = false
+			}
+		case "reopened":
+			if !open {
+				open, from = true, e.GetCreatedAt()
+			}
+		}
+	}
+	if open {
+		// fall back to the issue state if its events are not fully synced
+		to := end
+		if i.ClosedAt != nil && !i.ClosedAt.Before(from) {
+			to = *i.ClosedAt
+		}
+		ivs = append(ivs, interval{from, to})
+	}
+	return ivs
+}
+
+// intervalsContain reports whether any of ivs contains t.
+func intervalsContain(ivs []interval, t time.Time) bool {
+	for _, iv := range ivs {
+		if !iv.start.After(t) && iv.end.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkOpenBuckets calls f with the index of each of buckets b in which
+// any of ivs is open. Each bucket is passed once.
+func walkOpenBuckets(ivs []interval, b buckets, f func(k int)) {
+	last := -1
+	for _, iv := range ivs {
+		k := b.index(iv.start)
+		if k <= last {
+			k = last + 1
+		}
+		for ; k <= b.index(iv.end); k++ {
+			f(k)
+			last = k
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// flowChart draws a cumulative flow diagram of issues, which stacks the
+// number of issues in each state at the end of each of buckets b. states
+// are label patterns in workflow order, and an open issue is in the last
+// state that matches any label it had then, or just open if none does.
+func flowChart(rc *repoClient, per *period, b buckets, states []string) *chart {
+	end := rc.EndTime()
+
+	// counts[0] are closed issues, counts[1] are issues open in none of
+	// the states, and counts[n+2] are issues in states[n]
+	l := b.index(end) + 1
+	counts := make([][]int, len(states)+2)
+	for n := range counts {
+		counts[n] = make([]int, l)
+	}
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isPullRequest {
+			return
+		}
+		// issues still open, and labels still on them, are so until just
+		// after end
+		opens := rc.OpenIntervals(i, end.Add(time.Nanosecond))
+		labels := rc.LabelIntervals(i, end.Add(time.Nanosecond))
+		for k := b.index(*i.CreatedAt); k < l; k++ {
+			t := b.start(k + 1)
+			if t.After(end) {
+				t = end
+			}
+			if !intervalsContain(opens, t) {
+				counts[0][k]++
+				continue
+			}
+			state := 1
+			for n, pattern := range states {
+				for name, ivs := range labels {
+					if matchLabel(pattern, name) && intervalsContain(ivs, t) {
+						state = n + 2
+						break
+					}
+				}
+			}
+			counts[state][k]++
+		}
+	})
+
+	c := newTimeChart(per, b, "Cumulative Flow of Issues", "", "Count")
+	c.stacked = true
+	c.add("Closed", per.seqInts(counts[0], b).floats())
+	for n := len(states) - 1; n >= 0; n-- {
+		c.add(states[n], per.seqInts(counts[n+2], b).floats())
+	}
+	c.add("Open", per.seqInts(counts[1], b).floats())
+	return c
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	// forecastHistory is the number of past weeks to resample throughput
+	// from, and forecastHorizon is the number of weeks to forecast.
+	forecastHistory = 26
+	forecastHorizon = 26
+	// forecastRuns is the number of Monte Carlo runs.
+	forecastRuns = 1000
+	// clearHorizon is the most weeks to wait for the backlog to clear.
+	clearHorizon = 520
+)
+
+// throughput is the number of issues opened and closed in a week.
+type throughput struct {
+	opened, closed int
+}
+
+// weeklyThroughput returns the throughput of each of n weeks before end.
+func weeklyThroughput(rc *repoClient, end time.Time, n int) []throughput {
+	ts := make([]throughput, n)
+	start := end.Add(-time.Duration(n) * WeekDuration)
+	week := func(t time.Time) int { return int(t.Sub(start) / WeekDuration) }
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isPullRequest {
+			return
+		}
+		if inPeriod(*i.CreatedAt, start, end) {
+			ts[week(*i.CreatedAt)].opened++
+		}
+		if i.ClosedAt != nil && inPeriod(*i.ClosedAt, start, end) {
+			ts[week(*i.ClosedAt)].closed++
+		}
+	})
+	return ts
+}
+
+// backlogForecast is a Monte Carlo forecast of open issues, which resamples
+// the weekly throughput of the past for each future week.
+type backlogForecast struct {
+	// open is the number of open issues at the start of the forecast.
+	open int
+	// low, median and high are the 10th, 50th and 90th percentiles of
+	// open issues at the end of each future week.
+	low, median, high []float64
+	// cleared and clearedLate are the median and 90th percentile of the
+	// weeks until no issue is open, as issues are opened and closed at the
+	// resampled rates, or -1 if that is not within clearHorizon weeks.
+	cleared, clearedLate int
+}
+
+func newBacklogForecast(rc *repoClient, end time.Time) *backlogForecast {
+	f := &backlogForecast{}
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if !isPullRequest && isOpenAt(rc, i, end) {
+			f.open++
+		}
+	})
+	ts := weeklyThroughput(rc, end, forecastHistory)
+	// a fixed seed keeps reports of the same data the same
+	r := rand.New(rand.NewSource(1))
+
+	backlogs := make([][]float64, forecastHorizon)
+	var clears []float64
+	for run := 0; run < forecastRuns; run++ {
+		open := f.open
+		for w := range backlogs {
+			t := ts[r.Intn(len(ts))]
+			open += t.opened - t.closed
+			if open < 0 {
+				open = 0
+			}
+			backlogs[w] = append(backlogs[w], float64(open))
+		}
+
+		// new issues count too, or the date would be too early
+		left, weeks := f.open, 0
+		for ; left > 0 && weeks <= clearHorizon; weeks++ {
+			t := ts[r.Intn(len(ts))]
+			left += t.opened - t.closed
+		}
+		clears = append(clears, float64(weeks))
+	}
+
+	for _, bs := range backlogs {
+		f.low = append(f.low, percentile(bs, 0.10))
+		f.median = append(f.median, percentile(bs, 0.50))
+		f.high = append(f.high, percentile(bs, 0.90))
+	}
+	f.cleared, f.clearedLate = int(percentile(clears, 0.50)), int(percentile(clears, 0.90))
+	if f.cleared > clearHorizon {
+		f.cleared = -1
+	}
+	if f.clearedLate > clearHorizon {
+		f.clearedLate = -1
+	}
+	return f
+}
+
+// clearDate returns the date after weeks from t, or a note if weeks is -1.
+func clearDate(t time.Time, weeks int) string {
+	if weeks < 0 {
+		return fmt.Sprintf("not in %d years", clearHorizon/52)
+	}
+	return t.Add(time.Duration(weeks) * WeekDuration).Format(DateFormat)
+}
+
+// backlogForecastChart draws open issues in the weeks that throughput is
+// resampled from, and then the forecast of them, where all lines are the
+// same in the past.
+func backlogForecastChart(rc *repoClient, per *period, loc *time.Location) *chart {
+	b := newBuckets("week", loc, per.end.Add(-forecastHistory*WeekDuration))
+	open := make(seqFloats, b.count(per.end))
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isPullRequest {
+			return
+		}
+		ivs := rc.OpenIntervals(i, per.end.Add(time.Nanosecond))
+		for k := range open {
+			// count issues open at the end of the week
+			t := b.start(k + 1)
+			if t.After(per.end) {
+				t = per.end
+			}
+			if intervalsContain(ivs, t) {
+				open[k]++
+			}
+		}
+	})
+
+	f := newBacklogForecast(rc, per.end)
+	c := &chart{
+		title:   "Forecast of Open Issues",
+		xLabel:  fmt.Sprintf("Week, forecast after %s", per.end.In(loc).Format(DateFormat)),
+		yLabel:  "Count",
+		buckets: &b,
+	}
+	c.add("Median", append(append(seqFloats(nil), open...), f.median...))
+	c.add("10th percentile", append(append(seqFloats(nil), open...), f.low...))
+	c.add("90th percentile", append(append(seqFloats(nil), open...), f.high...))
+	return c
+}
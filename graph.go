@@ -1,15 +1,10 @@
 package main
 
 import (
-	"fmt"
 	"sort"
 	"time"
 
 	"github.com/bmizerany/perks/quantile"
-	"github.com/gonum/plot"
-	"github.com/gonum/plot/plotter"
-	"github.com/gonum/plot/plotutil"
-	"github.com/gonum/plot/vg"
 	"github.com/google/go-github/github"
 )
 
@@ -30,27 +25,45 @@ func newPeriod(rc *repoClient, start, end time.Time) *period {
 	return p
 }
 
-func (p *period) seqInts(a []int, interval time.Duration) seqInts {
-	i := p.start.Sub(p.rc.StartTime()) / interval
-	j := p.end.Sub(p.rc.StartTime()) / interval
+// seqInts returns the values of a, which are one for each of buckets b
+// since the start of the repo, in the buckets that overlap the period.
+func (p *period) seqInts(a []int, b buckets) seqInts {
+	i, j := p.bounds(len(a), b)
 	return a[i:j]
 }
 
-func (p *period) seqFloats(a []float64, interval time.Duration) seqFloats {
-	i := p.start.Sub(p.rc.StartTime()) / interval
-	j := p.end.Sub(p.rc.StartTime()) / interval
+func (p *period) seqFloats(a []float64, b buckets) seqFloats {
+	i, j := p.bounds(len(a), b)
 	return a[i:j]
 }
 
-func drawTotalIssues(rc *repoClient, per *period, filename string) {
-	start, end := rc.StartTime(), rc.EndTime()
+// bounds returns the indexes of the values of the period in n values, one
+// for each of buckets b, which are empty if the period is out of them.
+func (p *period) bounds(n int, b buckets) (int, int) {
+	i, j := b.index(p.start), b.count(p.end)
+	if j > n {
+		j = n
+	}
+	if j < 0 {
+		j = 0
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i > j {
+		i = j
+	}
+	return i, j
+}
+
+func totalIssuesChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
 
-	l := end.Sub(start)/DayDuration + 1
+	l := b.index(end) + 1
 	issues := make([]int, l)
 	prs := make([]int, l)
 	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
-		c := i.CreatedAt
-		for k := c.Sub(start) / DayDuration; k <= end.Sub(start)/DayDuration; k++ {
+		for k := b.index(*i.CreatedAt); k < l; k++ {
 			if isPullRequest {
 				prs[k]++
 			} else {
@@ -59,88 +72,50 @@ func drawTotalIssues(rc *repoClient, per *period, filename string) {
 		}
 	})
 
-	p, err := plot.New()
-	if err != nil {
-		panic(err)
-	}
-
-	p.Title.Text = "Total Issues/PR"
-	p.X.Label.Text = fmt.Sprintf("Date from %s to %s", per.start.Format(DateFormat), per.end.Format(DateFormat))
-	p.Y.Label.Text = "Count"
-	err = plotutil.AddLines(p, "issues", per.seqInts(issues, DayDuration), "PRs", per.seqInts(prs, DayDuration))
-	if err != nil {
-		panic(err)
-	}
-	p.X.Tick.Marker = newDayTicker(p.X.Tick.Marker, per.start)
-
-	// Save the plot to a PNG file.
-	if err := p.Save(defaultWidth, defaultHeight, filename); err != nil {
-		panic(err)
-	}
+	c := newTimeChart(per, b, "Total Issues/PR", "", "Count")
+	c.add("issues", per.seqInts(issues, b).floats())
+	c.add("PRs", per.seqInts(prs, b).floats())
+	return c
 }
 
-func drawOpenIssues(rc *repoClient, per *period, filename string) {
-	start, end := rc.StartTime(), rc.EndTime()
+func openIssuesChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
 
-	l := end.Sub(start)/DayDuration + 1
+	l := b.index(end) + 1
 	issues := make([]int, l)
 	prs := make([]int, l)
 	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
-		created := i.CreatedAt
-		closed := end
-		if i.ClosedAt != nil {
-			closed = *i.ClosedAt
-		}
-		for k := created.Sub(start) / DayDuration; k <= closed.Sub(start)/DayDuration; k++ {
+		walkOpenBuckets(rc.OpenIntervals(i, end), b, func(k int) {
 			if isPullRequest {
 				prs[k]++
 			} else {
 				issues[k]++
 			}
-		}
+		})
 	})
 
-	p, err := plot.New()
-	if err != nil {
-		panic(err)
-	}
-
-	p.Title.Text = "Open Issues/PR"
-	p.X.Label.Text = fmt.Sprintf("Date from %s to %s", per.start.Format(DateFormat), per.end.Format(DateFormat))
-	p.Y.Label.Text = "Count"
-	err = plotutil.AddLines(p, "issues", per.seqInts(issues, DayDuration), "PRs", per.seqInts(prs, DayDuration))
-	if err != nil {
-		panic(err)
-	}
-	p.X.Tick.Marker = newDayTicker(p.X.Tick.Marker, per.start)
-
-	// Save the plot to a PNG file.
-	if err := p.Save(defaultWidth, defaultHeight, filename); err != nil {
-		panic(err)
-	}
+	c := newTimeChart(per, b, "Open Issues/PR", "", "Count")
+	c.add("issues", per.seqInts(issues, b).floats())
+	c.add("PRs", per.seqInts(prs, b).floats())
+	return c
 }
 
-func drawOpenIssueFraction(rc *repoClient, per *period, filename string) {
-	start, end := rc.StartTime(), rc.EndTime()
+func openIssueFractionChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
 
-	l := end.Sub(start)/DayDuration + 1
+	l := b.index(end) + 1
 	totals := make([]int, l)
 	opens := make([]int, l)
 	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
 		if isPullRequest {
 			return
 		}
-		created := i.CreatedAt
-		closed := end
-		if i.ClosedAt != nil {
-			closed = *i.ClosedAt
-		}
-		for k := created.Sub(start) / DayDuration; k <= end.Sub(start)/DayDuration; k++ {
+		for k := b.index(*i.CreatedAt); k < l; k++ {
 			totals[k]++
 		}
-		for k := created.Sub(start) / DayDuration; k <= closed.Sub(start)/DayDuration; k++ {
+		walkOpenBuckets(rc.OpenIntervals(i, end), b, func(k int) {
 			opens[k]++
-		}
+		})
 	})
 
 	fractions := make([]float64, len(totals))
@@ -150,30 +125,52 @@ func drawOpenIssueFraction(rc *repoClient, per *period, filename string) {
 		}
 	}
 
-	p, err := plot.New()
-	if err != nil {
-		panic(err)
+	c := newTimeChart(per, b, "Open:Total Issues", "", "Fraction")
+	c.add("", per.seqFloats(fractions, b))
+	return c
+}
+
+func labeledOpenIssuesChart(rc *repoClient, per *period, b buckets, patterns []string) *chart {
+	end := rc.EndTime()
+	if len(patterns) == 0 {
+		patterns = topOpenLabels(rc, 5)
 	}
 
-	p.Title.Text = "Open:Total Issues"
-	p.X.Label.Text = fmt.Sprintf("Date from %s to %s", per.start.Format(DateFormat), per.end.Format(DateFormat))
-	p.Y.Label.Text = "Fraction"
-	err = plotutil.AddLines(p, per.seqFloats(fractions, DayDuration))
-	if err != nil {
-		panic(err)
+	l := b.index(end) + 1
+	counts := make([][]int, len(patterns))
+	for n := range counts {
+		counts[n] = make([]int, l)
 	}
-	p.X.Tick.Marker = newDayTicker(p.X.Tick.Marker, per.start)
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isPullRequest {
+			return
+		}
+		opens := rc.OpenIntervals(i, end)
+		labels := rc.LabelIntervals(i, end)
+		for n, pattern := range patterns {
+			var ivs []interval
+			for name, nivs := range labels {
+				if matchLabel(pattern, name) {
+					ivs = append(ivs, nivs...)
+				}
+			}
+			walkOpenBuckets(intersectIntervals(opens, mergeIntervals(ivs)), b, func(k int) {
+				counts[n][k]++
+			})
+		}
+	})
 
-	// Save the plot to a PNG file.
-	if err := p.Save(defaultWidth, defaultHeight, filename); err != nil {
-		panic(err)
+	c := newTimeChart(per, b, "Open Issues by Label", "", "Count")
+	for n, pattern := range patterns {
+		c.add(pattern, per.seqInts(counts[n], b).floats())
 	}
+	return c
 }
 
-func drawOpenIssueAge(rc *repoClient, per *period, filename string) {
-	start, end := rc.StartTime(), rc.EndTime()
+func openIssueAgeChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
 
-	l := end.Sub(start)/DayDuration + 1
+	l := b.index(end) + 1
 	qs := make([]*quantile.Stream, l)
 	for i := range qs {
 		qs[i] = quantile.NewTargeted(0.25, 0.50, 0.75)
@@ -182,43 +179,27 @@ func drawOpenIssueAge(rc *repoClient, per *period, filename string) {
 		if isPullRequest {
 			return
 		}
-		created := i.CreatedAt
-		closed := end
-		if i.ClosedAt != nil {
-			closed = *i.ClosedAt
-		}
-		firsti := created.Sub(start) / DayDuration
-		for k := firsti; k <= closed.Sub(start)/DayDuration; k++ {
-			qs[k].Insert(float64(k - firsti))
-		}
+		walkOpenBuckets(rc.OpenIntervals(i, end), b, func(k int) {
+			// the age in whole days at the end of the bucket
+			at := b.start(k + 1)
+			if at.After(end) {
+				at = end
+			}
+			qs[k].Insert(float64(at.Sub(*i.CreatedAt) / DayDuration))
+		})
 	})
 
-	p, err := plot.New()
-	if err != nil {
-		panic(err)
-	}
-
-	p.Title.Text = "Age of Open Issues"
-	p.X.Label.Text = fmt.Sprintf("Date from %s to %s", per.start.Format(DateFormat), per.end.Format(DateFormat))
-	p.Y.Label.Text = "Age (days)"
-	err = plotutil.AddLines(p, "25th percentile", per.seqFloats(quantileAt(qs, 0.25), DayDuration),
-		"Median", per.seqFloats(quantileAt(qs, 0.50), DayDuration),
-		"75th percentile", per.seqFloats(quantileAt(qs, 0.75), DayDuration))
-	if err != nil {
-		panic(err)
-	}
-	p.X.Tick.Marker = newDayTicker(p.X.Tick.Marker, per.start)
-
-	// Save the plot to a PNG file.
-	if err := p.Save(defaultWidth, defaultHeight, filename); err != nil {
-		panic(err)
-	}
+	c := newTimeChart(per, b, "Age of Open Issues", "", "Age (days)")
+	c.add("25th percentile", per.seqFloats(quantileAt(qs, 0.25), b))
+	c.add("Median", per.seqFloats(quantileAt(qs, 0.50), b))
+	c.add("75th percentile", per.seqFloats(quantileAt(qs, 0.75), b))
+	return c
 }
 
-func drawIssueSolvedDuration(rc *repoClient, per *period, filename string) {
+func issueSolvedDurationChart(rc *repoClient, per *period, b buckets) *chart {
 	start, end := rc.StartTime(), rc.EndTime()
 
-	l := end.Sub(start)/MonthDuration + 1
+	l := b.index(end) + 1
 	qs := make([]*quantile.Stream, l)
 	for i := range qs {
 		qs[i] = quantile.NewTargeted(0.50)
@@ -232,32 +213,188 @@ func drawIssueSolvedDuration(rc *repoClient, per *period, filename string) {
 		if i.ClosedAt != nil {
 			d = i.ClosedAt.Sub(*i.CreatedAt)
 		}
-		for k := i.CreatedAt.Sub(start) / MonthDuration; k <= end.Sub(start)/MonthDuration; k++ {
+		for k := b.index(*i.CreatedAt); k < l; k++ {
 			qs[k].Insert(float64(d) / float64(DayDuration))
 		}
 	})
 
-	p, err := plot.New()
-	if err != nil {
-		panic(err)
+	c := newTimeChart(per, b, "Solved Duration of Issues", "", "Duration (days)")
+	c.add("Median", per.seqFloats(quantileAt(qs, 0.50), b))
+	return c
+}
+
+// cohortBands are the bands of time from opening to closing by which the
+// cohort chart counts closed issues. Each band ends at d after opening,
+// and starts where the band before it ends, and the last band is unbounded.
+var cohortBands = []struct {
+	name string
+	d    time.Duration
+}{
+	{"Closed within 1 week", WeekDuration},
+	{"Closed in 1 week–1 month", 30 * DayDuration},
+	{"Closed in 1–3 months", 90 * DayDuration},
+	{"Closed in 3 months–1 year", 365 * DayDuration},
+	{"Closed after 1 year", 0},
+}
+
+func issueCohortChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
+
+	l := b.index(end) + 1
+	totals := make([]int, l)
+	opens := make([]int, l)
+	closed := make([][]int, len(cohortBands))
+	for n := range closed {
+		closed[n] = make([]int, l)
+	}
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isPullRequest || !i.CreatedAt.Before(per.end) {
+			return
+		}
+		k := b.index(*i.CreatedAt)
+		totals[k]++
+		// the fate of issues is as of the end of the period
+		if i.ClosedAt == nil || !i.ClosedAt.Before(per.end) {
+			opens[k]++
+			return
+		}
+		d := i.ClosedAt.Sub(*i.CreatedAt)
+		n := 0
+		for n < len(cohortBands)-1 && d > cohortBands[n].d {
+			n++
+		}
+		closed[n][k]++
+	})
+
+	fraction := func(counts []int) seqFloats {
+		fs := make(seqFloats, l)
+		for k := range counts {
+			if totals[k] != 0 {
+				fs[k] = float64(counts[k]) / float64(totals[k])
+			}
+		}
+		return per.seqFloats(fs, b)
+	}
+	// the bands of each group stack up to 1
+	c := newTimeChart(per, b, "Fate of Issues", "opened", "Fraction")
+	c.stacked = true
+	for n, band := range cohortBands {
+		c.add(band.name, fraction(closed[n]))
+	}
+	c.add("Still open", fraction(opens))
+	return c
+}
+
+func firstResponseTimeChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
+
+	l := b.index(end) + 1
+	qs := make([]*quantile.Stream, l)
+	for i := range qs {
+		qs[i] = quantile.NewTargeted(0.50, 0.90)
+	}
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isPullRequest {
+			return
+		}
+		// issues without response yet are left out
+		cm := rc.FirstResponse(i)
+		if cm == nil {
+			return
+		}
+		d := cm.GetCreatedAt().Sub(*i.CreatedAt)
+		qs[b.index(*i.CreatedAt)].Insert(float64(d) / float64(DayDuration))
+	})
+
+	c := newTimeChart(per, b, "Time to First Response of Issues", "opened", "Duration (days)")
+	c.add("Median", per.seqFloats(quantileAt(qs, 0.50), b))
+	c.add("90th percentile", per.seqFloats(quantileAt(qs, 0.90), b))
+	return c
+}
+
+func pullRequestReviewTimeChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
+
+	l := b.index(end) + 1
+	qs := make([]*quantile.Stream, l)
+	for i := range qs {
+		qs[i] = quantile.NewTargeted(0.50, 0.90)
 	}
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if !isPullRequest {
+			return
+		}
+		// pull requests without review yet are left out
+		r := rc.FirstReview(i)
+		if r == nil {
+			return
+		}
+		d := r.GetSubmittedAt().Sub(*i.CreatedAt)
+		qs[b.index(*i.CreatedAt)].Insert(float64(d) / float64(DayDuration))
+	})
 
-	p.Title.Text = "Solved Duration of Issues"
-	p.X.Label.Text = fmt.Sprintf("Month from %s to %s", per.start.Format(DateFormat), per.end.Format(DateFormat))
-	p.Y.Label.Text = "Duration (days)"
-	err = plotutil.AddLines(p, "Median", per.seqFloats(quantileAt(qs, 0.50), MonthDuration))
-	if err != nil {
-		panic(err)
+	c := newTimeChart(per, b, "Time to First Review of PRs", "opened", "Duration (days)")
+	c.add("Median", per.seqFloats(quantileAt(qs, 0.50), b))
+	c.add("90th percentile", per.seqFloats(quantileAt(qs, 0.90), b))
+	return c
+}
+
+func pullRequestMergeTimeChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
+
+	l := b.index(end) + 1
+	qs := make([]*quantile.Stream, l)
+	for i := range qs {
+		qs[i] = quantile.NewTargeted(0.50, 0.90)
 	}
-	p.X.Tick.Marker = newMonthTicker(p.X.Tick.Marker, per.start)
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if !isPullRequest {
+			return
+		}
+		pr := rc.PullRequest(i)
+		if pr == nil || pr.MergedAt == nil {
+			return
+		}
+		d := pr.MergedAt.Sub(*i.CreatedAt)
+		qs[b.index(*i.CreatedAt)].Insert(float64(d) / float64(DayDuration))
+	})
+
+	c := newTimeChart(per, b, "Time to Merge of PRs", "opened", "Duration (days)")
+	c.add("Median", per.seqFloats(quantileAt(qs, 0.50), b))
+	c.add("90th percentile", per.seqFloats(quantileAt(qs, 0.90), b))
+	return c
+}
+
+func pullRequestMergeRateChart(rc *repoClient, per *period, b buckets) *chart {
+	end := rc.EndTime()
 
-	// Save the plot to a PNG file.
-	if err := p.Save(defaultWidth, defaultHeight, filename); err != nil {
-		panic(err)
+	l := b.index(end) + 1
+	merged := make([]int, l)
+	closed := make([]int, l)
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if !isPullRequest || i.ClosedAt == nil {
+			return
+		}
+		k := b.index(*i.ClosedAt)
+		closed[k]++
+		if pr := rc.PullRequest(i); pr != nil && pr.MergedAt != nil {
+			merged[k]++
+		}
+	})
+
+	fractions := make([]float64, len(closed))
+	for i := range closed {
+		if closed[i] != 0 {
+			fractions[i] = float64(merged[i]) / float64(closed[i])
+		}
 	}
+
+	c := newTimeChart(per, b, "Merged:Closed PRs", "closed", "Fraction")
+	c.add("", per.seqFloats(fractions, b))
+	return c
 }
 
-func drawTopReleaseDownloads(rc *repoClient, per *period, filename string) {
+func topReleaseDownloadsChart(rc *repoClient, per *period) *chart {
 	var rs releases
 	rc.WalkReleases(func(r github.RepositoryRelease) {
 		var cnt int
@@ -282,27 +419,9 @@ func drawTopReleaseDownloads(rc *repoClient, per *period, filename string) {
 		downloads = append(downloads, rs[i].download)
 	}
 
-	p, err := plot.New()
-	if err != nil {
-		panic(err)
-	}
-
-	p.Title.Text = "Release Downloads"
-	p.Y.Label.Text = "Download Count"
-	if len(names) > 0 {
-		p.NominalX(names...)
-		bars, err := plotter.NewBarChart(ints(downloads), vg.Points(20))
-		if err != nil {
-			panic(err)
-		}
-		bars.LineStyle.Width = vg.Length(0)
-		p.Add(bars)
-	}
-
-	// Save the plot to a PNG file.
-	if err := p.Save(defaultWidth, defaultHeight, filename); err != nil {
-		panic(err)
-	}
+	c := &chart{title: "Release Downloads", yLabel: "Download Count", names: names}
+	c.add("downloads", seqInts(downloads).floats())
+	return c
 }
 
 type seqInts []int
@@ -310,16 +429,19 @@ type seqInts []int
 func (xys seqInts) Len() int                { return len(xys) }
 func (xys seqInts) XY(i int) (x, y float64) { return float64(i), float64(xys[i]) }
 
+func (xys seqInts) floats() seqFloats {
+	fs := make(seqFloats, len(xys))
+	for i := range xys {
+		fs[i] = float64(xys[i])
+	}
+	return fs
+}
+
 type seqFloats []float64
 
 func (xys seqFloats) Len() int                { return len(xys) }
 func (xys seqFloats) XY(i int) (x, y float64) { return float64(i), xys[i] }
 
-type ints []int
-
-func (a ints) Len() int            { return len(a) }
-func (a ints) Value(i int) float64 { return float64(a[i]) }
-
 type release struct {
 	name     string
 	download int
@@ -338,36 +460,3 @@ func quantileAt(ss []*quantile.Stream, q float64) seqFloats {
 	}
 	return fs
 }
-
-type dateTicker struct {
-	plot.Ticker
-	start    time.Time
-	interval time.Duration
-}
-
-func (dt *dateTicker) Ticks(min, max float64) []plot.Tick {
-	ts := dt.Ticker.Ticks(min, max)
-	for i, t := range ts {
-		if t.Label != "" {
-			t.Label = dt.start.Add(time.Duration(t.Value) * dt.interval).Format(DateFormat)
-		}
-		ts[i] = t
-	}
-	return ts
-}
-
-func newDayTicker(t plot.Ticker, start time.Time) plot.Ticker {
-	return &dateTicker{
-		Ticker:   t,
-		start:    start,
-		interval: DayDuration,
-	}
-}
-
-func newMonthTicker(t plot.Ticker, start time.Time) plot.Ticker {
-	return &dateTicker{
-		Ticker:   t,
-		start:    start,
-		interval: MonthDuration,
-	}
-}
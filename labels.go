@@ -0,0 +1,200 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// labelPatterns is a flag.Value that collects label patterns.
+// A pattern ending with "*" matches labels by prefix, e.g., "area/*",
+// and other patterns match labels exactly.
+type labelPatterns []string
+
+func (ps *labelPatterns) String() string { return strings.Join(*ps, ",") }
+
+func (ps *labelPatterns) Set(v string) error {
+	*ps = append(*ps, v)
+	return nil
+}
+
+func matchLabel(pattern, label string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(label, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == label
+}
+
+// labelFilter is a flag.Value that selects issues by their labels.
+// A pattern prefixed with "-" excludes issues that have a matching label,
+// and other patterns include only issues that have a matching label.
+type labelFilter struct {
+	include, exclude labelPatterns
+	// base is a filter that issues must pass too, if not nil.
+	base *labelFilter
+}
+
+func (f *labelFilter) String() string {
+	s := f.include.String()
+	for _, p := range f.exclude {
+		if s != "" {
+			s += ","
+		}
+		s += "-" + p
+	}
+	return s
+}
+
+func (f *labelFilter) Set(v string) error {
+	if strings.HasPrefix(v, "-") {
+		return f.exclude.Set(strings.TrimPrefix(v, "-"))
+	}
+	return f.include.Set(v)
+}
+
+// with returns a filter of patterns that issues must pass in addition to
+// the filter, so that its includes narrow down rather than add to those of
+// the filter. A nil filter passes every issue.
+func (f *labelFilter) with(patterns []string) *labelFilter {
+	c := &labelFilter{base: f}
+	for _, p := range patterns {
+		c.Set(p)
+	}
+	return c
+}
+
+// Match reports whether the issue with labels passes the filter.
+func (f *labelFilter) Match(labels []github.Label) bool {
+	if f.base != nil && !f.base.Match(labels) {
+		return false
+	}
+	included := len(f.include) == 0
+	for _, l := range labels {
+		for _, p := range f.exclude {
+			if matchLabel(p, l.GetName()) {
+				return false
+			}
+		}
+		for _, p := range f.include {
+			if matchLabel(p, l.GetName()) {
+				included = true
+			}
+		}
+	}
+	return included
+}
+
+// LabelIntervals returns the intervals in which the issue had each label,
+// built from its labeled and unlabeled events. A label that the issue
+// still has is regarded as kept until end.
+func (c *repoClient) LabelIntervals(i github.Issue, end time.Time) map[string][]interval {
+	events := c.IssueEvents(i)
+
+	// undo the events on the current labels to get the labels at creation
+	has := make(map[string]bool)
+	for _, l := range i.Labels {
+		has[l.GetName()] = true
+	}
+	for k := len(events) - 1; k >= 0; k-- {
+		switch events[k].GetEvent() {
+		case "labeled":
+			delete(has, events[k].Label.GetName())
+		case "unlabeled":
+			has[events[k].Label.GetName()] = true
+		}
+	}
+
+	from := make(map[string]time.Time)
+	for l := range has {
+		from[l] = i.GetCreatedAt()
+	}
+	ivs := make(map[string][]interval)
+	for _, e := range events {
+		name := e.Label.GetName()
+		switch e.GetEvent() {
+		case "labeled":
+			if _, ok := from[name]; !ok {
+				from[name] = e.GetCreatedAt()
+			}
+		case "unlabeled":
+			if t, ok := from[name]; ok {
+				ivs[name] = append(ivs[name], interval{t, e.GetCreatedAt()})
+				delete(from, name)
+			}
+		}
+	}
+	for name, t := range from {
+		ivs[name] = append(ivs[name], interval{t, end})
+	}
+	return ivs
+}
+
+// mergeIntervals returns the union of ivs as sorted disjoint intervals.
+func mergeIntervals(ivs []interval) []interval {
+	sorted := append([]interval(nil), ivs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start.Before(sorted[j].start) })
+	var merged []interval
+	for _, iv := range sorted {
+		if n := len(merged); n > 0 && !iv.start.After(merged[n-1].end) {
+			if iv.end.After(merged[n-1].end) {
+				merged[n-1].end = iv.end
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// intersectIntervals returns the intersection of sorted disjoint
+// intervals a and b.
+func intersectIntervals(a, b []interval) []interval {
+	var ivs []interval
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start, end := a[i].start, a[i].end
+		if b[j].start.After(start) {
+			start = b[j].start
+		}
+		if b[j].end.Before(end) {
+			end = b[j].end
+		}
+		if !start.After(end) {
+			ivs = append(ivs, interval{start, end})
+		}
+		if a[i].end.Before(b[j].end) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return ivs
+}
+
+// topOpenLabels returns at most n labels that are on the most open issues.
+func topOpenLabels(rc *repoClient, n int) []string {
+	counts := make(map[string]int)
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isPullRequest || i.ClosedAt != nil {
+			return
+		}
+		for _, l := range i.Labels {
+			counts[l.GetName()]++
+		}
+	})
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
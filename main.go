@@ -6,86 +6,511 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/gonum/plot/vg"
 )
 
 const (
-	DayDuration   = 24 * time.Hour
-	WeekDuration  = 7 * DayDuration
-	MonthDuration = 30 * DayDuration
-	DateFormat    = "2006-01-02"
+	DayDuration  = 24 * time.Hour
+	WeekDuration = 7 * DayDuration
+	DateFormat   = "2006-01-02"
+)
+
+// graphWidth and graphHeight are the size of saved graphs.
+var (
+	graphWidth  = 6 * vg.Inch
+	graphHeight = 4 * vg.Inch
+)
 
-	defaultWidth  = 6 * vg.Inch
-	defaultHeight = 4 * vg.Inch
+// exit codes of the tool
+const (
+	exitOK = 0
+	// exitError is for failures to fetch, store or draw data.
+	exitError = 1
+	// exitUsage is for malformed flags or arguments, as package flag uses.
+	exitUsage = 2
+	// exitViolation is for repos that break rules.
+	exitViolation = 3
 )
 
 func main() {
+	os.Exit(run())
+}
+
+func run() int {
 	owner := flag.String("owner", "coreos", "the owner in github")
 	repo := flag.String("repo", "etcd", "the repo of the owner in github")
+	org := flag.String("org", "", "analyze all repos of the organization in github, instead of -owner and -repo")
 	token := flag.String("token", "", "access token for github")
+	apiURL := flag.String("api-url", "", "the API endpoint of GitHub Enterprise, e.g. https://github.example.com/api/v3/ (default github.com)")
+	uploadURL := flag.String("upload-url", "", "the upload endpoint of GitHub Enterprise, e.g. https://github.example.com/api/uploads/ (default github.com)")
 	start := flag.String("start-date", "", "start date of the graph, in format 2000-Jan-01 or 2000-Jan")
 	end := flag.String("end-date", "", "end date of the graph, in format 2000-Jan-01 or 2000-Jan")
-	flag.Parse()
+	storeKind := flag.String("store", "json", "the storage of fetched data, json or bolt")
+	cacheDir := flag.String("cache-dir", "cache", "the directory to store fetched data in")
+	var labels labelFilter
+	flag.Var(&labels, "label", "only analyze issues with the label, or without it if prefixed with '-', where a trailing '*' matches label prefix; may be repeated")
+	var gr granularity
+	flag.Var(&gr, "interval", "the interval of time series graphs, day, week, month or quarter, or graph=interval for the named graph only, e.g., solved_duration=quarter; may be repeated (default day for states and month for durations and rates)")
+	timezone := flag.String("timezone", "UTC", "the timezone of calendar days, e.g., America/Los_Angeles or Local")
+	var labelSeries labelPatterns
+	flag.Var(&labelSeries, "label-series", "label or label prefix ending with '*' to draw open issues of as a series; may be repeated (default top 5 labels of open issues)")
+	survivalBy := flag.String("survival-by", "", "draw a survival curve of issues for each year opened, or each -label-series label, if year or label (default one curve of all issues)")
+	var flowStates labelPatterns
+	flag.Var(&flowStates, "flow-state", "label or label prefix ending with '*' of a state of open issues in workflow order, to draw the cumulative flow of; may be repeated")
+	ignoreBots := flag.Bool("ignore-bots", false, "ignore comments from bots when finding the first response, as -exclude-bots does too")
+	format := flag.String("format", "png", "the format of graphs, png, svg, pdf or eps")
+	export := flag.String("export", "", "also export the data of graphs in format csv or json")
+	exportOnly := flag.Bool("export-only", false, "export the data of graphs instead of drawing them")
+	width := flag.Float64("width", 6, "the width of graphs in inches")
+	height := flag.Float64("height", 4, "the height of graphs in inches")
+	responders := flag.String("responders", "all", "whose comments count as the first response, all, members of the owner organization, or collaborators of the repo")
+	excludeMembers := flag.Bool("exclude-members", false, "exclude members of the owner organization from contributor graphs")
+	var accounts accountFilter
+	flag.BoolVar(&accounts.bots, "exclude-bots", false, "exclude issues, pull requests, comments and reviews by bots from all graphs")
+	flag.Var(&accounts.logins, "exclude-user", "exclude issues, pull requests, comments and reviews by the login, or logins with the prefix if ending with '*', from all graphs; may be repeated")
+	releaseMarkers := flag.Bool("release-markers", false, "mark releases on time series graphs")
+	outDir := flag.String("out-dir", ".", "the directory to write graphs and report into")
+	noBrowser := flag.Bool("no-browser", false, "do not open the report in the browser")
+	rulesFile := flag.String("rules", "", "the JSON file of rules to check the stats of each repo against")
+	addr := flag.String("addr", ":8080", "the address to listen on in serve mode")
+	refresh := flag.Duration("refresh", time.Hour, "the interval to refresh data from github in serve mode")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [serve] [flags] [owner/repo ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	// serve is a command instead of a flag, so it comes before the flags
+	args := os.Args[1:]
+	serving := len(args) > 0 && args[0] == "serve"
+	if serving {
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
+
+	switch *format {
+	case "png", "svg", "pdf", "eps":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown graph format %q\n", *format)
+		return exitUsage
+	}
+	if *width <= 0 || *height <= 0 {
+		fmt.Fprintf(os.Stderr, "graph size %gx%g is not positive\n", *width, *height)
+		return exitUsage
+	}
+	graphWidth, graphHeight = vg.Length(*width)*vg.Inch, vg.Length(*height)*vg.Inch
+	switch *export {
+	case "", "csv", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown export format %q\n", *export)
+		return exitUsage
+	}
+	out := output{dir: *outDir, format: *format, export: *export}
+	if *exportOnly {
+		if *export == "" {
+			fmt.Fprintf(os.Stderr, "-export-only needs -export\n")
+			return exitUsage
+		}
+		out.format = ""
+	}
+
+	valid := false
+	for _, g := range survivalGroups {
+		valid = valid || g == *survivalBy
+	}
+	if !valid {
+		fmt.Fprintf(os.Stderr, "unknown survival group %q, want year or label\n", *survivalBy)
+		return exitUsage
+	}
+	switch *responders {
+	case "all", "members", "collaborators":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown responders %q\n", *responders)
+		return exitUsage
+	}
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unknown timezone %q (%v)\n", *timezone, err)
+		return exitUsage
+	}
+	gr.loc = loc
+	startDate, err := parseDate(*start, loc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	endDate, err := parseDate(*end, loc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if !startDate.IsZero() && !endDate.IsZero() && !startDate.Before(endDate) {
+		fmt.Fprintf(os.Stderr, "-start-date %s is not before -end-date %s\n", *start, *end)
+		return exitUsage
+	}
+	var rules []rule
+	if *rulesFile != "" {
+		if rules, err = loadRules(*rulesFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+	// repos are in format owner/repo
+	repos := flag.Args()
+	for _, r := range repos {
+		parts := strings.Split(r, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			fmt.Fprintf(os.Stderr, "malformat repo %q, want owner/repo\n", r)
+			return exitUsage
+		}
+	}
 
 	if *token == "" {
 		if data, err := ioutil.ReadFile(".oauth2_token"); err == nil {
 			*token = string(data)
 		}
 	}
+	// progress goes to stderr, so that stdout has only the summary
 	if *token == "" {
-		fmt.Println("Using unauthenticated client because oauth2 token is unavailable,")
-		fmt.Println("whose rate is limited to 60 requests per hour.")
-		fmt.Println("Learn more about GitHub rate limiting at http://developer.github.com/v3/#rate-limiting.")
-		fmt.Println("If you want to use authenticated client, please save your oauth token into file './.oauth2_token'.")
+		fmt.Fprintln(os.Stderr, "Using unauthenticated client because oauth2 token is unavailable,")
+		fmt.Fprintln(os.Stderr, "whose rate is limited to 60 requests per hour.")
+		fmt.Fprintln(os.Stderr, "Learn more about GitHub rate limiting at http://developer.github.com/v3/#rate-limiting.")
+		fmt.Fprintln(os.Stderr, "If you want to use authenticated client, please save your oauth token into file './.oauth2_token'.")
 	} else {
-		fmt.Println("Using authenticated client whose rate is up to 5000 requests per hour.")
+		fmt.Fprintln(os.Stderr, "Using authenticated client whose rate is up to 5000 requests per hour.")
 	}
 
-	rc := newRepoClient(*owner, *repo, *token)
+	client, err := newGitHubClient(*token, *apiURL, *uploadURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating client (%v)\n", err)
+		return exitUsage
+	}
 
-	rc.LoadIssues()
-	rc.LoadReleases()
-	per := newPeriod(rc, parseDateString(*start), parseDateString(*end))
+	if *org != "" {
+		names, err := listOrgRepos(client, *org)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading %s: %v\n", *org, err)
+			return exitError
+		}
+		for _, name := range names {
+			repos = append(repos, *org+"/"+name)
+		}
+	}
+	if len(repos) == 0 {
+		repos = []string{*owner + "/" + *repo}
+	}
+	// repo names are case insensitive, and a repo given twice would be
+	// counted twice in all repos
+	seen := make(map[string]bool)
+	var unique []string
+	for _, r := range repos {
+		if !seen[strings.ToLower(r)] {
+			seen[strings.ToLower(r)] = true
+			unique = append(unique, r)
+		}
+	}
+	repos = unique
 
-	drawTotalIssues(rc, per, "total_issues.png")
-	drawOpenIssues(rc, per, "open_issues.png")
-	drawOpenIssueFraction(rc, per, "open_fraction.png")
-	drawOpenIssueAge(rc, per, "open_age.png")
-	drawIssueSolvedDuration(rc, per, "solved_duration.png")
-	drawTopReleaseDownloads(rc, per, "top_downloads.png")
-	buildImagesHTML("images.html", "total_issues.png", "open_issues.png", "open_fraction.png", "open_age.png", "solved_duration.png", "top_downloads.png")
-	fmt.Printf("saved images and browsing html\n")
+	st, err := newStore(*storeKind, *cacheDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening store (%v)\n", err)
+		return exitError
+	}
 
-	startBrowser("images.html")
+	// load syncs the data of repo r from github into the store, and
+	// loads it into a new client
+	load := func(r string) (*repoClient, error) {
+		fmt.Fprintf(os.Stderr, "loading %s...\n", r)
+		parts := strings.Split(r, "/")
+		rc := newRepoClient(client, parts[0], parts[1], st)
+		rc.labels = &labels
+		rc.accounts = &accounts
+		rc.loc = loc
+		for _, f := range []func() error{rc.LoadIssues, rc.LoadIssueEvents, rc.LoadComments, rc.LoadPullRequests, rc.LoadReleases} {
+			if err := f(); err != nil {
+				return nil, fmt.Errorf("error loading %s: %v", r, err)
+			}
+		}
+		// bots excluded from all graphs are no responders either
+		rc.responders = &responderFilter{ignoreBots: *ignoreBots || accounts.bots}
+		if *responders != "all" {
+			logins, err := rc.LoadMembers(*responders)
+			if err != nil {
+				return nil, fmt.Errorf("error loading %s: %v", r, err)
+			}
+			rc.responders.logins = logins
+		}
+		if *excludeMembers {
+			members, err := rc.LoadMembers("members")
+			if err != nil {
+				return nil, fmt.Errorf("error loading %s: %v", r, err)
+			}
+			rc.contributors = &contributorFilter{members: members}
+		}
+		return rc, nil
+	}
+
+	opts := graphOptions{labelSeries: labelSeries, survivalBy: *survivalBy, flowStates: flowStates, releaseMarkers: *releaseMarkers, granularity: &gr}
+	if serving {
+		s := &server{repos: repos, load: load, labels: labels, opts: opts}
+		if err := s.run(*addr, *refresh); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	// rcs are the clients of the repos named by names, which leave out the
+	// repos whose history is out of the period
+	var rcs []*repoClient
+	var names []string
+	for _, r := range repos {
+		rc, err := load(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		if per := newPeriod(rc, startDate, endDate); !per.start.Before(per.end) {
+			msg := fmt.Sprintf("empty period of %s from %s to %s", r, per.start.Format(DateFormat), per.end.Format(DateFormat))
+			// the dates are wrong for a single repo, but may be right for
+			// other repos of many
+			if len(repos) == 1 {
+				fmt.Fprintln(os.Stderr, msg)
+				return exitUsage
+			}
+			fmt.Fprintf(os.Stderr, "skipping %s\n", msg)
+			continue
+		}
+		rcs = append(rcs, rc)
+		names = append(names, r)
+	}
+	if len(rcs) == 0 {
+		fmt.Fprintln(os.Stderr, "empty period of all repos")
+		return exitUsage
+	}
+
+	var sections []reportSection
+	var violations []violation
+	analyze := func(rc *repoClient, title, dir string) error {
+		per := newPeriod(rc, startDate, endDate)
+		graphs, err := drawGraphs(graphsOf(rc, per, opts), out, dir)
+		if err != nil {
+			return fmt.Errorf("error drawing graphs of %s (%v)", title, err)
+		}
+		sections = append(sections, newReportSection(title, rc, per, graphs))
+		// rules are for each repo rather than all repos together
+		if rc.parts == nil {
+			violations = append(violations, checkRules(rules, title, rc, per)...)
+		}
+		return nil
+	}
+	if len(rcs) == 1 {
+		err = analyze(rcs[0], names[0], "")
+	} else {
+		all := newAggregateClient(rcs)
+		all.labels = &labels
+		all.accounts = &accounts
+		if err = analyze(all, "All repos", "all"); err == nil {
+			for k, rc := range rcs {
+				if err = analyze(rc, names[k], strings.Replace(names[k], "/", "_", -1)); err != nil {
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	sum := newSummary(sections)
+	sum.Violations = violations
+	if out.format != "" {
+		sum.Report = filepath.Join(out.dir, "report.html")
+		if err := buildReport(sum.Report, sections); err != nil {
+			fmt.Fprintf(os.Stderr, "error building report (%v)\n", err)
+			return exitError
+		}
+		fmt.Fprintf(os.Stderr, "saved images and report\n")
+		if !*noBrowser {
+			startBrowser(sum.Report)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "exported data\n")
+	}
+	if err := printSummary(sum); err != nil {
+		fmt.Fprintf(os.Stderr, "error printing summary (%v)\n", err)
+		return exitError
+	}
+	if len(violations) > 0 {
+		for _, v := range violations {
+			fmt.Fprintf(os.Stderr, "rule violated: %v\n", v)
+		}
+		return exitViolation
+	}
+	return exitOK
 }
 
-func parseDateString(date string) time.Time {
-	if date == "" {
-		return time.Time{}
+// graph is a chart to draw, named by its file name without extension.
+type graph struct {
+	name        string
+	description string
+	chart       func() *chart
+}
+
+// graphOptions select how to draw graphs.
+type graphOptions struct {
+	// labelSeries are the label patterns of the open_labels graph.
+	labelSeries []string
+	// survivalBy groups issues into survival curves, year or label.
+	survivalBy string
+	// flowStates are the label patterns of the states of the flow graph.
+	flowStates []string
+	// releaseMarkers marks releases on time series graphs.
+	releaseMarkers bool
+	// granularity selects the buckets of time series graphs.
+	granularity *granularity
+}
+
+// timeGraphs are the time series graphs, in buckets of unit unless flag
+// -interval selects otherwise. Graphs of states are daily, and graphs of
+// durations and rates are monthly.
+var timeGraphs = []struct {
+	name, unit, description string
+	chart                   func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart
+}{
+	{"total_issues", "day", "Number of issues and pull requests created up to each %s.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return totalIssuesChart(rc, per, b)
+	}},
+	{"open_issues", "day", "Number of issues and pull requests open in each %s.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return openIssuesChart(rc, per, b)
+	}},
+	{"open_fraction", "day", "Fraction of the issues created so far that are open in each %s.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return openIssueFractionChart(rc, per, b)
+	}},
+	{"open_labels", "day", "Number of open issues that have each label in each %s.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return labeledOpenIssuesChart(rc, per, b, opts.labelSeries)
+	}},
+	{"flow", "day", "Cumulative flow of issues, which stacks the number of issues closed, in each -flow-state and open in none of them, at the end of each %s.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return flowChart(rc, per, b, opts.flowStates)
+	}},
+	{"open_age", "day", "Quantiles of the age of the issues open in each %s.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return openIssueAgeChart(rc, per, b)
+	}},
+	{"solved_duration", "month", "Median days to close the issues created up to each %s, where open issues count as open for the whole history.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return issueSolvedDurationChart(rc, per, b)
+	}},
+	{"cohorts", "month", "Fraction of the issues opened in each %s that are closed within a week, in a week to a month, in 1 to 3 months, in 3 months to a year and after a year of opening, and that are still open, at the end of the period, stacked up to 1.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return issueCohortChart(rc, per, b)
+	}},
+	{"first_response", "month", "Days from opening an issue to the first comment by someone else, by the %s opened.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return firstResponseTimeChart(rc, per, b)
+	}},
+	{"pr_review_time", "month", "Days from opening a pull request to the first review by someone else, by the %s opened.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return pullRequestReviewTimeChart(rc, per, b)
+	}},
+	{"pr_merge_time", "month", "Days from opening a pull request to merging it, by the %s opened.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return pullRequestMergeTimeChart(rc, per, b)
+	}},
+	{"pr_merge_rate", "month", "Fraction of the pull requests closed in each %s that are merged.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return pullRequestMergeRateChart(rc, per, b)
+	}},
+	{"release_downloads", "week", "Downloads of the 5 most downloaded releases in each %s, from the download counts recorded on each day that releases are fetched.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return releaseDownloadsChart(rc, per, b)
+	}},
+	{"asset_downloads", "week", "Downloads of the 5 most downloaded types of release assets, such as linux-amd64, in each %s, from the download counts recorded on each day that releases are fetched.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return assetDownloadsChart(rc, per, b)
+	}},
+	{"active_authors", "month", "Number of distinct authors of the issues and pull requests opened in each %s.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return activeAuthorsChart(rc, per, b)
+	}},
+	{"new_contributors", "month", "Number of authors who opened their first issue or pull request, and their first pull request, in each %s.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return newContributorsChart(rc, per, b)
+	}},
+	{"pr_retention", "month", "Fraction of the authors of a first pull request in each %s who opened a second one by the end of the period.", func(rc *repoClient, per *period, opts graphOptions, b buckets) *chart {
+		return pullRequestRetentionChart(rc, per, b)
+	}},
+}
+
+func graphsOf(rc *repoClient, per *period, opts graphOptions) []graph {
+	gr := opts.granularity
+	var graphs []graph
+	for _, g := range timeGraphs {
+		g, b := g, gr.buckets(g.name, g.unit, rc.StartTime())
+		graphs = append(graphs, graph{g.name, fmt.Sprintf(g.description, b.unit), func() *chart { return g.chart(rc, per, opts, b) }})
 	}
-	if t, err := time.Parse("2006-Jan-02", date); err == nil {
-		return t
+	graphs = append(graphs,
+		graph{"survival", "Fraction of the issues opened in the period that are still open after each number of days, estimated by Kaplan–Meier, where issues open at the end of the period count as open up to then.", func() *chart { return survivalChart(rc, per, opts.survivalBy, opts.labelSeries, gr.loc) }},
+		graph{"backlog_forecast", "Open issues in the past weeks, and the forecast of them with 80% confidence, which resamples the weekly numbers of opened and closed issues of the past weeks.", func() *chart { return backlogForecastChart(rc, per, gr.loc) }},
+		graph{"top_downloads", "Total downloads of the 10 most downloaded releases created in the period.", func() *chart { return topReleaseDownloadsChart(rc, per) }},
+	)
+	if opts.releaseMarkers {
+		for k := range graphs {
+			draw := graphs[k].chart
+			graphs[k].chart = func() *chart {
+				c := draw()
+				c.addReleaseMarkers(rc)
+				return c
+			}
+		}
 	}
-	if t, err := time.Parse("2006-Jan-02", fmt.Sprint(date, "-01")); err == nil {
-		return t
+	return graphs
+}
+
+// output selects the files to write for each graph.
+type output struct {
+	// dir is the directory to write files into.
+	dir string
+	// format is the image format, or empty to write no images.
+	format string
+	// export is the data format, or empty to write no data.
+	export string
+}
+
+// drawGraphs writes the files of the graphs into dir under out.dir, and
+// returns the drawn graphs.
+func drawGraphs(graphs []graph, out output, dir string) ([]drawnGraph, error) {
+	if err := os.MkdirAll(filepath.Join(out.dir, dir), 0755); err != nil {
+		return nil, err
 	}
-	fmt.Fprintf(os.Stderr, "malformat date string %q\n", date)
-	os.Exit(1)
-	return time.Time{}
+	var drawn []drawnGraph
+	for _, g := range graphs {
+		c := g.chart()
+		d := drawnGraph{Name: g.name, Title: c.title, Description: g.description, Chart: c}
+		if out.format != "" {
+			d.Image = filepath.Join(dir, g.name+"."+out.format)
+			d.file = filepath.Join(out.dir, d.Image)
+			if err := c.save(d.file); err != nil {
+				return nil, err
+			}
+		}
+		if out.export != "" {
+			d.data = filepath.Join(out.dir, dir, g.name+"."+out.export)
+			if err := c.export(d.data, out.export); err != nil {
+				return nil, err
+			}
+		}
+		drawn = append(drawn, d)
+	}
+	return drawn, nil
 }
 
-func buildImagesHTML(html string, images ...string) {
-	var body string
-	for _, i := range images {
-		body = body + fmt.Sprintf("<img src=%q>\n", i)
+// parseDate parses date in format 2000-Jan-01 or 2000-Jan as the start of
+// the day in loc. An empty date is the zero time.
+func parseDate(date string, loc *time.Location) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
 	}
-	err := ioutil.WriteFile(html, []byte(body), 0666)
-	if err != nil {
-		panic(err)
+	if t, err := time.ParseInLocation("2006-Jan-02", date, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-Jan-02", fmt.Sprint(date, "-01"), loc); err == nil {
+		return t, nil
 	}
+	return time.Time{}, fmt.Errorf("malformat date string %q", date)
 }
 
 func startBrowser(url string) bool {
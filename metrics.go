@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// health is the current state of a repo exposed as Prometheus gauges.
+// The stats of both windows end now, so their open issues are the same.
+type health struct {
+	week  stats
+	month stats
+	// downloads is the total download count of all release assets.
+	downloads int
+}
+
+func healthOf(rc *repoClient) health {
+	end := rc.EndTime()
+	h := health{
+		week:  computeStats(rc, end.Add(-WeekDuration), end),
+		month: computeStats(rc, end.Add(-30*DayDuration), end),
+	}
+	rc.WalkReleases(func(r github.RepositoryRelease) {
+		for _, a := range r.Assets {
+			h.downloads += a.GetDownloadCount()
+		}
+	})
+	return h
+}
+
+type sample struct {
+	// labels are extra labels in addition to host, owner and repo,
+	// formatted as `name="value"`.
+	labels []string
+	value  float64
+}
+
+var metricFamilies = []struct {
+	name, help string
+	samples    func(h health) []sample
+}{
+	{"issue_analyzer_open_issues", "Number of open issues.", func(h health) []sample {
+		return []sample{{nil, float64(h.month.OpenIssues)}}
+	}},
+	{"issue_analyzer_open_pull_requests", "Number of open pull requests.", func(h health) []sample {
+		return []sample{{nil, float64(h.month.OpenPRs)}}
+	}},
+	{"issue_analyzer_open_issue_age_seconds", "Quantiles of the age of open issues.", func(h health) []sample {
+		day := DayDuration.Seconds()
+		return []sample{
+			{[]string{`quantile="0.25"`}, h.month.OpenAgeP25 * day},
+			{[]string{`quantile="0.5"`}, h.month.OpenAgeMedian * day},
+			{[]string{`quantile="0.75"`}, h.month.OpenAgeP75 * day},
+		}
+	}},
+	{"issue_analyzer_issues_opened", "Number of issues opened in the last window.", func(h health) []sample {
+		return []sample{
+			{[]string{`window="7d"`}, float64(h.week.OpenedIssues)},
+			{[]string{`window="30d"`}, float64(h.month.OpenedIssues)},
+		}
+	}},
+	{"issue_analyzer_issues_closed", "Number of issues closed in the last window.", func(h health) []sample {
+		return []sample{
+			{[]string{`window="7d"`}, float64(h.week.ClosedIssues)},
+			{[]string{`window="30d"`}, float64(h.month.ClosedIssues)},
+		}
+	}},
+	{"issue_analyzer_release_downloads", "Total download count of all release assets.", func(h health) []sample {
+		return []sample{{nil, float64(h.downloads)}}
+	}},
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetrics writes the gauges of the repos in the Prometheus text
+// exposition format.
+func writeMetrics(w io.Writer, rcs []*repoClient) error {
+	hs := make([]health, len(rcs))
+	for k, rc := range rcs {
+		hs[k] = healthOf(rc)
+	}
+	for _, f := range metricFamilies {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", f.name, f.help, f.name); err != nil {
+			return err
+		}
+		for k, rc := range rcs {
+			for _, s := range f.samples(hs[k]) {
+				labels := append([]string{
+					fmt.Sprintf(`host="%s"`, labelValueReplacer.Replace(rc.client.BaseURL.Host)),
+					fmt.Sprintf(`owner="%s"`, labelValueReplacer.Replace(rc.owner)),
+					fmt.Sprintf(`repo="%s"`, labelValueReplacer.Replace(rc.repo)),
+				}, s.labels...)
+				if _, err := fmt.Fprintf(w, "%s{%s} %g\n", f.name, strings.Join(labels, ","), s.value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// pullsBatch is the number of pull requests to store with their reviews
+// at a time.
+const pullsBatch = 100
+
+// LoadPullRequests syncs the pull requests in the repo and their reviews.
+func (c *repoClient) LoadPullRequests() error {
+	syncedAt, err := c.syncTime("pulls")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	updated, err := c.fetchPullRequests(syncedAt)
+	if err != nil {
+		return err
+	}
+	// store pull requests and their reviews in batches from the least
+	// recently updated, so that a sync cut off, e.g., by the rate limit,
+	// resumes after the last stored batch
+	sort.SliceStable(updated, func(i, j int) bool { return updated[i].GetUpdatedAt().Before(updated[j].GetUpdatedAt()) })
+	for start := 0; start == 0 || start < len(updated); start += pullsBatch {
+		end := start + pullsBatch
+		if end > len(updated) {
+			end = len(updated)
+		}
+		batch := updated[start:end]
+		rs := make([]record, len(batch))
+		var reviews []record
+		for k, pr := range batch {
+			rs[k] = record{Key: strconv.Itoa(pr.GetNumber()), Time: pr.GetCreatedAt(), Value: trimPullRequest(pr)}
+			fetched, err := c.fetchReviews(pr.GetNumber())
+			if err != nil {
+				return err
+			}
+			for _, r := range fetched {
+				reviews = append(reviews, record{Key: strconv.Itoa(r.GetID()), Time: r.GetSubmittedAt(), Value: trimReview(r)})
+			}
+		}
+		// pull requests updated at the sync time are fetched again next time
+		batchSyncedAt := now
+		if end < len(updated) {
+			batchSyncedAt = batch[len(batch)-1].GetUpdatedAt()
+		}
+		// store reviews first, so they are fetched again if storing pulls fails
+		if err := c.put("reviews", reviews, batchSyncedAt); err != nil {
+			return err
+		}
+		if err := c.put("pulls", rs, batchSyncedAt); err != nil {
+			return err
+		}
+		if end < len(updated) {
+			fmt.Fprintf(os.Stderr, "stored %d pull requests and their reviews...\n", end)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "stored %d updated pull requests\n", len(updated))
+
+	c.pulls = make(map[int]*github.PullRequest)
+	err = c.walkStored("pulls", func(data []byte) error {
+		pr := &github.PullRequest{}
+		if err := json.Unmarshal(data, pr); err != nil {
+			return err
+		}
+		c.pulls[pr.GetNumber()] = pr
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.reviews = make(map[int][]*github.PullRequestReview)
+	err = c.walkStored("reviews", func(data []byte) error {
+		r := &github.PullRequestReview{}
+		if err := json.Unmarshal(data, r); err != nil {
+			return err
+		}
+		n := numberOfURL(r.GetPullRequestURL())
+		c.reviews[n] = append(c.reviews[n], r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, rs := range c.reviews {
+		sort.SliceStable(rs, func(i, j int) bool { return rs[i].GetSubmittedAt().Before(rs[j].GetSubmittedAt()) })
+	}
+	return nil
+}
+
+// trimPullRequest returns the fields of pr that are analyzed. Listed pull
+// requests carry their head and base repos, which are tens of KB, and
+// would be written to the store again on each batch.
+func trimPullRequest(pr *github.PullRequest) *github.PullRequest {
+	return &github.PullRequest{
+		Number:    pr.Number,
+		CreatedAt: pr.CreatedAt,
+		MergedAt:  pr.MergedAt,
+		User:      trimUser(pr.User),
+	}
+}
+
+// trimReview returns the fields of r that are analyzed.
+func trimReview(r *github.PullRequestReview) *github.PullRequestReview {
+	return &github.PullRequestReview{
+		ID:             r.ID,
+		SubmittedAt:    r.SubmittedAt,
+		PullRequestURL: r.PullRequestURL,
+		User:           trimUser(r.User),
+	}
+}
+
+// trimUser returns the fields of u that tell who it is, or nil if u is nil.
+func trimUser(u *github.User) *github.User {
+	if u == nil {
+		return nil
+	}
+	return &github.User{Login: u.Login, Type: u.Type}
+}
+
+// PullRequest returns the details of the pull request issue, or nil if
+// they are not synced.
+func (c *repoClient) PullRequest(i github.Issue) *github.PullRequest {
+	return c.part(i).pulls[i.GetNumber()]
+}
+
+// FirstReview returns the first submitted review on the pull request issue
+// by someone other than its author, or nil if there is none.
+func (c *repoClient) FirstReview(i github.Issue) *github.PullRequestReview {
+	for _, r := range c.part(i).reviews[i.GetNumber()] {
+		if r.SubmittedAt != nil && r.User.GetLogin() != i.User.GetLogin() && c.accounts.Match(r.User) {
+			return r
+		}
+	}
+	return nil
+}
+
+// fetchPullRequests lists pull requests in the repo that are updated at or
+// after since. A zero since lists all pull requests.
+func (c *repoClient) fetchPullRequests(since time.Time) ([]*github.PullRequest, error) {
+	opt := &github.PullRequestListOptions{
+		State:     "all",
+		Sort:      "updated",
+		Direction: "desc",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	var pulls []*github.PullRequest
+	for {
+		prs, resp, err := c.client.PullRequests.List(context.TODO(), c.owner, c.repo, opt)
+		if err != nil {
+			return nil, fmt.Errorf("listing pull requests (%v)", err)
+		}
+		// pull requests are listed from the most recently updated
+		done := false
+		for _, pr := range prs {
+			if pr.GetUpdatedAt().Before(since) {
+				done = true
+				break
+			}
+			pulls = append(pulls, pr)
+		}
+		if done || resp.NextPage == 0 {
+			break
+		}
+		opt.ListOptions.Page = resp.NextPage
+		fmt.Fprintf(os.Stderr, "list %d pull requests...\n", len(pulls))
+	}
+	return pulls, nil
+}
+
+func (c *repoClient) fetchReviews(number int) ([]*github.PullRequestReview, error) {
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+	var reviews []*github.PullRequestReview
+	for {
+		rs, resp, err := c.client.PullRequests.ListReviews(context.TODO(), c.owner, c.repo, number, opt)
+		if err != nil {
+			return nil, fmt.Errorf("listing reviews of pull request %d (%v)", number, err)
+		}
+		reviews = append(reviews, rs...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return reviews, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gonum/plot"
+	"github.com/gonum/plot/vg"
+	"github.com/gonum/plot/vg/draw"
+	"github.com/google/go-github/github"
+)
+
+// marker labels the k-th values of the series of a time series chart,
+// such as the releases in the k-th bucket.
+type marker struct {
+	k    int
+	name string
+}
+
+// addReleaseMarkers marks the buckets of a time series chart in which
+// releases are created, by the tags of the releases.
+func (c *chart) addReleaseMarkers(rc *repoClient) {
+	if !c.isTimeSeries() {
+		return
+	}
+	names := make(map[int][]string)
+	for _, r := range sortedReleases(rc) {
+		if k := c.buckets.index(r.GetCreatedAt().Time); k >= 0 && k < c.len() {
+			names[k] = append(names[k], r.GetTagName())
+		}
+	}
+	for k, ns := range names {
+		c.markers = append(c.markers, marker{k, strings.Join(ns, ", ")})
+	}
+	sort.Slice(c.markers, func(i, j int) bool { return c.markers[i].k < c.markers[j].k })
+}
+
+// markerPlotter draws markers as dashed vertical lines labeled at the top.
+type markerPlotter struct {
+	markers []marker
+	font    vg.Font
+}
+
+func (mp markerPlotter) Plot(c draw.Canvas, p *plot.Plot) {
+	trX, _ := p.Transforms(&c)
+	line := draw.LineStyle{Color: color.Gray{Y: 128}, Width: vg.Points(0.5), Dashes: []vg.Length{vg.Points(2), vg.Points(2)}}
+	text := draw.TextStyle{Color: color.Gray{Y: 96}, Font: mp.font, Rotation: math.Pi / 2, XAlign: draw.XRight, YAlign: draw.YBottom}
+	for _, m := range mp.markers {
+		x := trX(float64(m.k))
+		c.StrokeLine2(line, x, c.Min.Y, x, c.Max.Y)
+		c.FillText(text, vg.Point{X: x - vg.Points(2), Y: c.Max.Y}, m.name)
+	}
+}
+
+// sortedReleases returns the releases of the repo other than drafts in
+// the order of creation.
+func sortedReleases(rc *repoClient) []github.RepositoryRelease {
+	var rs []github.RepositoryRelease
+	rc.WalkReleases(func(r github.RepositoryRelease) {
+		if !r.GetDraft() && r.CreatedAt != nil {
+			rs = append(rs, r)
+		}
+	})
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].CreatedAt.Before(rs[j].CreatedAt.Time) })
+	return rs
+}
+
+// releaseRow is the number of issues opened and closed, and pull requests
+// merged, since the release before a release until the release.
+type releaseRow struct {
+	Tag          string    `json:"tag"`
+	Date         time.Time `json:"date"`
+	IssuesOpened int       `json:"issues_opened"`
+	IssuesClosed int       `json:"issues_closed"`
+	PRsMerged    int       `json:"prs_merged"`
+}
+
+// releaseRows returns the rows of the releases created in the period. The
+// first release counts from the start of the repo.
+func releaseRows(rc *repoClient, per *period) []releaseRow {
+	rs := sortedReleases(rc)
+	rows := make([]releaseRow, len(rs))
+	for k, r := range rs {
+		rows[k] = releaseRow{Tag: r.GetTagName(), Date: r.GetCreatedAt().Time}
+	}
+	// row returns the row of the first release after t, or nil if none
+	row := func(t time.Time) *releaseRow {
+		k := sort.Search(len(rows), func(k int) bool { return rows[k].Date.After(t) })
+		if k == len(rows) {
+			return nil
+		}
+		return &rows[k]
+	}
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isPullRequest {
+			if pr := rc.PullRequest(i); pr != nil && pr.MergedAt != nil {
+				if r := row(*pr.MergedAt); r != nil {
+					r.PRsMerged++
+				}
+			}
+			return
+		}
+		if r := row(*i.CreatedAt); r != nil {
+			r.IssuesOpened++
+		}
+		if i.ClosedAt != nil {
+			if r := row(*i.ClosedAt); r != nil {
+				r.IssuesClosed++
+			}
+		}
+	})
+
+	var inPer []releaseRow
+	for _, r := range rows {
+		if inPeriod(r.Date, per.start, per.end) {
+			inPer = append(inPer, r)
+		}
+	}
+	return inPer
+}
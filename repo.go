@@ -4,45 +4,152 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 )
 
-const cacheDir = "cache"
-
 type repoClient struct {
 	client *github.Client
+	store  store
 	owner  string
 	repo   string
 
 	issues   []*github.Issue
 	releases []*github.RepositoryRelease
+	// downloads are the snapshots of download counts in time order.
+	downloads []downloadSnapshot
+	// events maps issue number to its events in time order.
+	events map[int][]*github.IssueEvent
+	// comments maps issue number to its comments in time order.
+	comments map[int][]*github.IssueComment
+	// pulls maps pull request number to its details.
+	pulls map[int]*github.PullRequest
+	// reviews maps pull request number to its reviews in time order.
+	reviews map[int][]*github.PullRequestReview
+
+	// loc is the location of the calendar days of download snapshots.
+	loc *time.Location
+
+	// labels selects the issues to walk, if not nil.
+	labels *labelFilter
+	// accounts selects the issues, comments and reviews by their authors,
+	// if not nil.
+	accounts *accountFilter
+	// responders selects the comments that count as responses.
+	responders *responderFilter
+	// contributors selects the authors that count as contributors, if not
+	// nil.
+	contributors *contributorFilter
+
+	// parts are the clients aggregated by this one, if any.
+	parts []*repoClient
+	// owners maps the URL of each issue of parts to the part it is
+	// loaded by, which holds even if the repo is renamed or transferred.
+	owners map[string]*repoClient
+}
+
+// name returns the name of the repo in the store, which includes the API
+// host to tell apart repos of the same name on different hosts.
+func (c *repoClient) name() string { return c.client.BaseURL.Host + "/" + c.owner + "/" + c.repo }
+
+func (c *repoClient) LoadIssues() error {
+	syncedAt, err := c.syncTime("issues")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	updated, err := allIssuesInRepo(c.client, c.owner, c.repo, syncedAt)
+	if err != nil {
+		return err
+	}
+	rs := make([]record, len(updated))
+	for k, i := range updated {
+		rs[k] = record{Key: strconv.Itoa(i.GetNumber()), Time: i.GetCreatedAt(), Value: i}
+	}
+	if err := c.put("issues", rs, now); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "stored %d updated issues\n", len(updated))
+
+	c.issues = nil
+	return c.walkStored("issues", func(data []byte) error {
+		i := &github.Issue{}
+		if err := json.Unmarshal(data, i); err != nil {
+			return err
+		}
+		c.issues = append(c.issues, i)
+		return nil
+	})
+}
+
+func (c *repoClient) LoadReleases() error {
+	syncedAt, err := c.syncTime("releases")
+	if err != nil {
+		return err
+	}
+	// releases are few and change rarely, so refetch all of them once a
+	// calendar day, which snapshots their download counts of the day
+	now := time.Now()
+	if syncedAt.In(c.loc).Format(DateFormat) != now.In(c.loc).Format(DateFormat) {
+		fetched, err := c.fetchReleases()
+		if err != nil {
+			return err
+		}
+		// downloads go first, so that the day is refetched if they fail
+		if err := c.putDownloads(fetched, now); err != nil {
+			return err
+		}
+		rs := make([]record, len(fetched))
+		for k, r := range fetched {
+			rs[k] = record{Key: strconv.Itoa(r.GetID()), Time: r.GetCreatedAt().Time, Value: r}
+		}
+		if err := c.put("releases", rs, now); err != nil {
+			return err
+		}
+	}
+
+	c.releases = nil
+	err = c.walkStored("releases", func(data []byte) error {
+		r := &github.RepositoryRelease{}
+		if err := json.Unmarshal(data, r); err != nil {
+			return err
+		}
+		c.releases = append(c.releases, r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.loadDownloads()
 }
 
-func (c *repoClient) LoadIssues() {
-	issueCacheFilename := fmt.Sprintf("%s_%s_issues.cache", c.owner, c.repo)
-	issueCachePath := filepath.Join(cacheDir, issueCacheFilename)
-	if err := readJson(issueCachePath, &c.issues); err == nil {
-		return
+func (c *repoClient) syncTime(kind string) (time.Time, error) {
+	t, err := c.store.SyncTime(c.name(), kind)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading sync time of %s (%v)", kind, err)
 	}
-	c.issues = allIssuesInRepo(c.client, c.owner, c.repo)
-	writeJson(issueCachePath, c.issues)
+	return t, nil
 }
 
-func (c *repoClient) LoadReleases() {
-	cacheFilename := fmt.Sprintf("%s_%s_releases.cache", c.owner, c.repo)
-	cachePath := filepath.Join(cacheDir, cacheFilename)
-	if err := readJson(cachePath, &c.releases); err == nil {
-		return
+func (c *repoClient) put(kind string, rs []record, syncedAt time.Time) error {
+	if err := c.store.Put(c.name(), kind, rs, syncedAt); err != nil {
+		return fmt.Errorf("storing %s (%v)", kind, err)
 	}
-	c.releases = c.fetchReleases()
-	writeJson(cachePath, c.releases)
+	return nil
+}
+
+func (c *repoClient) walkStored(kind string, f func(data []byte) error) error {
+	if err := c.store.Walk(c.name(), kind, time.Time{}, time.Time{}, f); err != nil {
+		return fmt.Errorf("loading stored %s (%v)", kind, err)
+	}
+	return nil
 }
 
 func (c *repoClient) StartTime() time.Time {
@@ -59,6 +166,12 @@ func (c *repoClient) EndTime() time.Time { return time.Now() }
 
 func (c *repoClient) WalkIssues(f func(issue github.Issue, isPullRequest bool)) {
 	for _, issue := range c.issues {
+		if c.labels != nil && !c.labels.Match(issue.Labels) {
+			continue
+		}
+		if !c.accounts.Match(issue.User) {
+			continue
+		}
 		f(*issue, issue.PullRequestLinks != nil)
 	}
 }
@@ -69,7 +182,7 @@ func (c *repoClient) WalkReleases(f func(r github.RepositoryRelease)) {
 	}
 }
 
-func (c *repoClient) fetchReleases() []*github.RepositoryRelease {
+func (c *repoClient) fetchReleases() ([]*github.RepositoryRelease, error) {
 	opt := &github.ListOptions{
 		PerPage: 100,
 	}
@@ -77,8 +190,7 @@ func (c *repoClient) fetchReleases() []*github.RepositoryRelease {
 	for i := 0; ; i++ {
 		rs, resp, err := c.client.Repositories.ListReleases(context.TODO(), c.owner, c.repo, opt)
 		if err != nil {
-			fmt.Printf("error listing releases (%v)\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("listing releases (%v)", err)
 		}
 		releases = append(releases, rs...)
 		if resp.NextPage == 0 {
@@ -86,19 +198,22 @@ func (c *repoClient) fetchReleases() []*github.RepositoryRelease {
 		}
 		opt.Page = resp.NextPage
 	}
-	return releases
+	return releases, nil
 }
 
-func allIssuesInRepo(client *github.Client, owner, repo string) []*github.Issue {
+// allIssuesInRepo lists issues in the repo that are updated at or after since.
+// A zero since lists all issues.
+func allIssuesInRepo(client *github.Client, owner, repo string, since time.Time) ([]*github.Issue, error) {
 	rate, _, err := client.RateLimits(context.TODO())
 	if err != nil {
-		fmt.Printf("error fetching rate limit (%v)\n", err)
+		fmt.Fprintf(os.Stderr, "error fetching rate limit (%v)\n", err)
 	} else {
-		fmt.Printf("API Rate Limit: %s\n", rate)
+		fmt.Fprintf(os.Stderr, "API Rate Limit: %s\n", rate)
 	}
 
 	opt := &github.IssueListByRepoOptions{
 		State: "all",
+		Since: since,
 		ListOptions: github.ListOptions{
 			// github API limits to 100 now, but try to fetch more
 			PerPage: 300,
@@ -108,62 +223,109 @@ func allIssuesInRepo(client *github.Client, owner, repo string) []*github.Issue
 	for i := 0; ; i++ {
 		is, resp, err := client.Issues.ListByRepo(context.TODO(), owner, repo, opt)
 		if err != nil {
-			fmt.Printf("error listing issues (%v)\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("listing issues (%v)", err)
 		}
 		issues = append(issues, is...)
 		if resp.NextPage == 0 {
 			break
 		}
 		opt.ListOptions.Page = resp.NextPage
-		fmt.Printf("list %d issues...\n", len(issues))
+		fmt.Fprintf(os.Stderr, "list %d issues...\n", len(issues))
 	}
-	return issues
+	return issues, nil
 }
 
-func readJson(filename string, v interface{}) error {
-	data, err := ioutil.ReadFile(filename)
-	haveCachedIssue := err == nil
-	isUpToDate := time.Now().Sub(fileModTime(filename)) < DayDuration
-	if haveCachedIssue && isUpToDate {
-		if err := json.Unmarshal(data, v); err != nil {
-			return fmt.Errorf("error loading cached data from file %s (%v)", filename, err)
+// newGitHubClient returns a GitHub client. Empty apiURL and uploadURL
+// select the endpoints of github.com.
+func newGitHubClient(token, apiURL, uploadURL string) (*github.Client, error) {
+	var c *http.Client
+	if token != "" {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		c = oauth2.NewClient(oauth2.NoContext, ts)
+	}
+	client := github.NewClient(c)
+	if apiURL != "" {
+		u, err := parseEndpoint(apiURL)
+		if err != nil {
+			return nil, err
 		}
-		return nil
+		client.BaseURL = u
+	}
+	if uploadURL != "" {
+		u, err := parseEndpoint(uploadURL)
+		if err != nil {
+			return nil, err
+		}
+		client.UploadURL = u
 	}
-	return fmt.Errorf("outdated cache file")
+	return client, nil
 }
 
-func writeJson(filename string, v interface{}) {
-	if data, err := json.Marshal(v); err != nil {
-		fmt.Printf("error marshaling issues (%v)\n", err)
-	} else if err := ioutil.WriteFile(filename, data, 0600); err != nil {
-		fmt.Printf("error caching issues into file (%v)\n", err)
-	} else {
-		fmt.Printf("cached issues in file %q for fast retrieval\n", filename)
+func newRepoClient(client *github.Client, owner, repo string, st store) *repoClient {
+	return &repoClient{client: client, store: st, owner: owner, repo: repo, loc: time.UTC}
+}
+
+// newAggregateClient returns a client that walks the data of all parts
+// as a single repo. Its owner is "all" and its repo is empty.
+func newAggregateClient(parts []*repoClient) *repoClient {
+	c := &repoClient{owner: "all", parts: parts, owners: make(map[string]*repoClient)}
+	for _, p := range parts {
+		c.issues = append(c.issues, p.issues...)
+		c.releases = append(c.releases, p.releases...)
+		c.downloads = append(c.downloads, p.downloads...)
+		for _, i := range p.issues {
+			c.owners[i.GetURL()] = p
+		}
 	}
+	return c
 }
 
-func fileModTime(name string) time.Time {
-	f, err := os.Open(name)
-	if err != nil {
-		return time.Time{}
+// part returns the client of the repo that the issue belongs to.
+func (c *repoClient) part(i github.Issue) *repoClient {
+	if p, ok := c.owners[i.GetURL()]; ok {
+		return p
 	}
-	st, err := f.Stat()
-	if err != nil {
-		return time.Time{}
+	return c
+}
+
+// listOrgRepos lists the names of the repos owned by the organization,
+// leaving out forks.
+func listOrgRepos(client *github.Client, org string) ([]string, error) {
+	opt := &github.RepositoryListByOrgOptions{
+		Type: "sources",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
 	}
-	return st.ModTime()
+	var names []string
+	for {
+		rs, resp, err := client.Repositories.ListByOrg(context.TODO(), org, opt)
+		if err != nil {
+			return nil, fmt.Errorf("listing repos of %s (%v)", org, err)
+		}
+		for _, r := range rs {
+			names = append(names, r.GetName())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.ListOptions.Page = resp.NextPage
+	}
+	return names, nil
 }
 
-func newRepoClient(owner, repo, token string) *repoClient {
-	var c *http.Client
-	if token != "" {
-		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-		c = oauth2.NewClient(oauth2.NoContext, ts)
+// parseEndpoint parses an API endpoint, e.g.,
+// https://github.example.com/api/v3/, and ensures its trailing slash.
+func parseEndpoint(s string) (*url.URL, error) {
+	if !strings.HasSuffix(s, "/") {
+		s += "/"
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed endpoint %q (%v)", s, err)
 	}
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		panic(err)
+	if u.Host == "" {
+		return nil, fmt.Errorf("endpoint %q has no host", s)
 	}
-	return &repoClient{client: github.NewClient(c), owner: owner, repo: repo}
+	return u, nil
 }
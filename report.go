@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// reportSection is the part of the report about a repo, or about all
+// analyzed repos together.
+type reportSection struct {
+	Title      string
+	Start, End time.Time
+	Summary    []summaryRow
+	Graphs     []drawnGraph
+	// Releases are of a repo, rather than of all repos together.
+	Releases []releaseRow
+
+	// stats are of the period, and previous are of the period before.
+	stats, previous stats
+	// forecast starts at the end of the period.
+	forecast *backlogForecast
+}
+
+type summaryRow struct {
+	Name     string
+	Value    string
+	Previous string
+	Delta    string
+}
+
+// drawnGraph is a graph whose image is saved in a file, or served at
+// an URL.
+type drawnGraph struct {
+	Name        string
+	Title       string
+	Description string
+	// Image is the path of the image file relative to the report.
+	Image string
+	URL   string
+	// Chart is embedded into the report as JSON to draw interactively.
+	Chart *chart
+
+	// file is the path of the image file, and data is the path of the
+	// exported data file, if any.
+	file, data string
+}
+
+// DataURI returns the image inlined as data URI, or empty string if the
+// image cannot be shown inline.
+func (g drawnGraph) DataURI() (template.URL, error) {
+	var mime string
+	switch filepath.Ext(g.file) {
+	case ".png":
+		mime = "image/png"
+	case ".svg":
+		mime = "image/svg+xml"
+	default:
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(g.file)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
+
+func newReportSection(title string, rc *repoClient, per *period, graphs []drawnGraph) reportSection {
+	cur := computeStats(rc, per.start, per.end)
+	l := per.end.Sub(per.start)
+	prev := computeStats(rc, per.start.Add(-l), per.start)
+	ints := func(name string, v, p int) summaryRow {
+		return summaryRow{name, fmt.Sprint(v), fmt.Sprint(p), fmt.Sprintf("%+d", v-p)}
+	}
+	days := func(name string, v, p float64) summaryRow {
+		return summaryRow{name, fmt.Sprintf("%.1f", v), fmt.Sprintf("%.1f", p), fmt.Sprintf("%+.1f", v-p)}
+	}
+	f := newBacklogForecast(rc, per.end)
+	var releases []releaseRow
+	if rc.parts == nil {
+		releases = releaseRows(rc, per)
+	}
+	return reportSection{
+		Title: title,
+		Start: per.start,
+		End:   per.end,
+		Summary: []summaryRow{
+			ints("Open issues", cur.OpenIssues, prev.OpenIssues),
+			ints("Open PRs", cur.OpenPRs, prev.OpenPRs),
+			days("Median age of open issues (days)", cur.OpenAgeMedian, prev.OpenAgeMedian),
+			days("Median time to close issues (days)", cur.CloseTimeMedian, prev.CloseTimeMedian),
+			ints("Issues opened", cur.OpenedIssues, prev.OpenedIssues),
+			ints("Issues closed", cur.ClosedIssues, prev.ClosedIssues),
+			{Name: "No open issues at the current rates of opening and closing by", Value: fmt.Sprintf("%s (90%%: %s)", clearDate(per.end, f.cleared), clearDate(per.end, f.clearedLate))},
+		},
+		Graphs:   graphs,
+		Releases: releases,
+		stats:    cur,
+		previous: prev,
+		forecast: f,
+	}
+}
+
+// buildReport writes the sections into a self-contained HTML file.
+func buildReport(filename string, sections []reportSection) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := writeReport(f, sections); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func writeReport(w io.Writer, sections []reportSection) error {
+	return reportTemplate.Execute(w, struct {
+		Generated time.Time
+		Script    template.JS
+		Sections  []reportSection
+	}{time.Now(), template.JS(chartScript), sections})
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format(DateFormat) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Issue Report{{range .Sections}} - {{.Title}}{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.graph { display: inline-block; vertical-align: top; width: 600px; margin: 0 1em 1em 0; }
+.graph img { max-width: 100%; }
+.note { color: #666; }
+.tip { position: absolute; display: none; pointer-events: none; background: #fff; border: 1px solid #999; padding: 0.2em 0.5em; font-size: 12px; white-space: nowrap; }
+.legend span { cursor: pointer; margin-right: 1em; font-size: 13px; }
+.legend .off { opacity: 0.4; }
+</style>
+<script>{{.Script}}</script>
+</head>
+<body>
+<p class="note">Generated at {{.Generated.Format "2006-01-02 15:04 MST"}}</p>
+<p class="note">Hover on a chart to see values, drag to zoom into a date range, double click to reset the zoom, and click a legend to toggle its line.</p>
+{{range $si, $s := .Sections}}
+<h1>{{.Title}}</h1>
+<p>Period from {{date .Start}} to {{date .End}}, compared with the previous period of the same length.</p>
+<table>
+<tr><th></th><th>Now</th><th>Previous</th><th>Delta</th></tr>
+{{range .Summary}}<tr><td>{{.Name}}</td><td>{{.Value}}</td><td>{{.Previous}}</td><td>{{.Delta}}</td></tr>
+{{end}}</table>
+{{with .Releases}}<h2>Releases</h2>
+<p>Issues opened and closed, and PRs merged, since the release before each release in the period.</p>
+<table>
+<tr><th>Release</th><th>Date</th><th>Issues opened</th><th>Issues closed</th><th>PRs merged</th></tr>
+{{range .}}<tr><td>{{.Tag}}</td><td>{{date .Date}}</td><td>{{.IssuesOpened}}</td><td>{{.IssuesClosed}}</td><td>{{.PRsMerged}}</td></tr>
+{{end}}</table>
+{{end}}{{range $gi, $g := .Graphs}}<div class="graph">
+<h2>{{.Title}}</h2>
+<p>{{.Description}}</p>
+<div id="chart-{{$si}}-{{$gi}}"></div>
+<script>renderChart(document.getElementById("chart-{{$si}}-{{$gi}}"), {{.Chart}});</script>
+<details><summary>Static image</summary>
+{{if .URL}}<img src="{{.URL}}">{{else}}{{with .DataURI}}<img src="{{.}}">{{else}}<a href="{{.Image}}">{{.Image}}</a>{{end}}{{end}}
+</details>
+</div>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// summary is the machine-readable summary of a run.
+type summary struct {
+	// Report is the path of the report, if built.
+	Report     string           `json:"report,omitempty"`
+	Repos      []sectionSummary `json:"repos"`
+	Violations []violation      `json:"violations,omitempty"`
+}
+
+type sectionSummary struct {
+	Name     string         `json:"name"`
+	Start    string         `json:"start"`
+	End      string         `json:"end"`
+	Stats    stats          `json:"stats"`
+	Previous stats          `json:"previous"`
+	Graphs   []graphSummary `json:"graphs"`
+	Releases []releaseRow   `json:"releases,omitempty"`
+	// BacklogCleared is the median date until no issue is open at the
+	// current rates of opening and closing issues, and BacklogClearedLate
+	// is its 90th percentile.
+	BacklogCleared     string `json:"backlog_cleared"`
+	BacklogClearedLate string `json:"backlog_cleared_p90"`
+}
+
+type graphSummary struct {
+	Name  string `json:"name"`
+	Image string `json:"image,omitempty"`
+	Data  string `json:"data,omitempty"`
+}
+
+func newSummary(sections []reportSection) summary {
+	var sum summary
+	for _, s := range sections {
+		ss := sectionSummary{
+			Name:               s.Title,
+			Start:              s.Start.Format(DateFormat),
+			End:                s.End.Format(DateFormat),
+			Stats:              s.stats,
+			Previous:           s.previous,
+			Releases:           s.Releases,
+			BacklogCleared:     clearDate(s.End, s.forecast.cleared),
+			BacklogClearedLate: clearDate(s.End, s.forecast.clearedLate),
+		}
+		for _, g := range s.Graphs {
+			ss.Graphs = append(ss.Graphs, graphSummary{g.Name, g.file, g.data})
+		}
+		sum.Repos = append(sum.Repos, ss)
+	}
+	return sum
+}
+
+// printSummary prints the summary as JSON on stdout.
+func printSummary(sum summary) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sum)
+}
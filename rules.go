@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// rule is a threshold on a stat of each analyzed repo, e.g.,
+//
+//	{"name": "no P0 untouched over 7 days", "stat": "untouched_max_days", "labels": ["priority/P0"], "max": 7}
+//
+// Stat is a key of stats in JSON, and labels filter issues as flag -label
+// does, among the issues that pass it.
+type rule struct {
+	Name   string   `json:"name"`
+	Stat   string   `json:"stat"`
+	Labels []string `json:"labels,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
+
+// violation is a rule broken by a repo.
+type violation struct {
+	Repo  string  `json:"repo"`
+	Rule  string  `json:"rule"`
+	Stat  string  `json:"stat"`
+	Value float64 `json:"value"`
+	// Want describes the threshold, e.g., "at most 7".
+	Want string `json:"want"`
+}
+
+func (v violation) String() string {
+	return fmt.Sprintf("%s: %s: %s is %.1f, want %s", v.Repo, v.Rule, v.Stat, v.Value, v.Want)
+}
+
+// loadRules reads a JSON array of rules from file.
+func loadRules(filename string) ([]rule, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var rules []rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("malformat rules file %q (%v)", filename, err)
+	}
+	known := statValues(stats{})
+	for _, r := range rules {
+		if _, ok := known[r.Stat]; !ok {
+			var names []string
+			for name := range known {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("rule %q has unknown stat %q, want one of %s", r.Name, r.Stat, strings.Join(names, ", "))
+		}
+		if r.Min == nil && r.Max == nil {
+			return nil, fmt.Errorf("rule %q has neither min nor max", r.Name)
+		}
+	}
+	return rules, nil
+}
+
+// statValues returns the stats keyed by their names in JSON.
+func statValues(st stats) map[string]float64 {
+	// stats are plain numbers, which always marshal and unmarshal
+	data, _ := json.Marshal(st)
+	var m map[string]float64
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// checkRules returns the rules broken by the stats of the repo in the
+// period.
+func checkRules(rules []rule, name string, rc *repoClient, per *period) []violation {
+	var vs []violation
+	for _, r := range rules {
+		v := *rc
+		v.labels = rc.labels.with(r.Labels)
+		value := statValues(computeStats(&v, per.start, per.end))[r.Stat]
+		if r.Min != nil && value < *r.Min {
+			vs = append(vs, violation{name, r.Name, r.Stat, value, fmt.Sprintf("at least %g", *r.Min)})
+		}
+		if r.Max != nil && value > *r.Max {
+			vs = append(vs, violation{name, r.Name, r.Stat, value, fmt.Sprintf("at most %g", *r.Max)})
+		}
+	}
+	return vs
+}
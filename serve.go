@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// server serves the report and graphs of repos over HTTP. It keeps the
+// data of repos in memory, and refreshes it from the store and github
+// periodically.
+//
+// The report of a repo is at /owner/repo/, and each graph is at
+// /owner/repo/<name>.<format>, where format is png, svg, pdf or eps for
+// the image, or csv or json for the data. Query parameters start and end
+// select the period, in format 2000-Jan-01 or 2000-Jan, each label
+// parameter filters issues as flag -label does, and each interval
+// parameter selects the interval of graphs as flag -interval does.
+//
+// The gauges of all repos are at /metrics for Prometheus to scrape.
+type server struct {
+	repos  []string
+	load   func(repo string) (*repoClient, error)
+	labels labelFilter
+	opts   graphOptions
+
+	mu  sync.RWMutex
+	rcs map[string]*repoClient
+}
+
+// run loads the repos, and then serves at addr while refreshing the repos
+// every interval. A failed refresh keeps serving the data loaded before.
+func (s *server) run(addr string, interval time.Duration) error {
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(interval) {
+			if err := s.refresh(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "serving at %s\n", addr)
+	return http.ListenAndServe(addr, s)
+}
+
+// refresh loads all repos into new clients, and then replaces the old
+// clients, which may still be in use by requests.
+func (s *server) refresh() error {
+	rcs := make(map[string]*repoClient)
+	for _, r := range s.repos {
+		rc, err := s.load(r)
+		if err != nil {
+			return err
+		}
+		rcs[r] = rc
+	}
+	s.mu.Lock()
+	s.rcs = rcs
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *server) repo(name string) *repoClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rcs[name]
+}
+
+// all returns the clients of all repos in order.
+func (s *server) all() []*repoClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rcs := make([]*repoClient, len(s.repos))
+	for k, r := range s.repos {
+		rcs[k] = s.rcs[r]
+	}
+	return rcs
+}
+
+var contentTypes = map[string]string{
+	"png":  "image/png",
+	"svg":  "image/svg+xml",
+	"pdf":  "application/pdf",
+	"eps":  "application/postscript",
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/json",
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		if err := indexTemplate.Execute(w, s.repos); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing index (%v)\n", err)
+		}
+		return
+	}
+	if r.URL.Path == "/metrics" {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := writeMetrics(w, s.all()); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing metrics (%v)\n", err)
+		}
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	name := parts[0] + "/" + parts[1]
+	rc := s.repo(name)
+	if rc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	rc, per, err := s.view(rc, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := s.opts
+	opts.granularity, err = s.opts.granularity.with(r.URL.Query()["interval"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	graphs := graphsOf(rc, per, opts)
+
+	if parts[2] == "" {
+		var drawn []drawnGraph
+		for _, g := range graphs {
+			c := g.chart()
+			u := url.URL{Path: g.name + ".svg", RawQuery: r.URL.RawQuery}
+			drawn = append(drawn, drawnGraph{Title: c.title, Description: g.description, URL: u.String(), Chart: c})
+		}
+		if err := writeReport(w, []reportSection{newReportSection(name, rc, per, drawn)}); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing report (%v)\n", err)
+		}
+		return
+	}
+
+	ext := path.Ext(parts[2])
+	format := strings.TrimPrefix(ext, ".")
+	ctype, ok := contentTypes[format]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	for _, g := range graphs {
+		if g.name != strings.TrimSuffix(parts[2], ext) {
+			continue
+		}
+		c := g.chart()
+		w.Header().Set("Content-Type", ctype)
+		if format == "csv" || format == "json" {
+			err = c.writeData(w, format)
+		} else {
+			err = c.writeImage(w, format)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing %s (%v)\n", r.URL.Path, err)
+		}
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// view returns a copy of the client that filters issues by the labels in
+// query too, and the period selected by query.
+func (s *server) view(rc *repoClient, query url.Values) (*repoClient, *period, error) {
+	start, err := parseDate(query.Get("start"), s.opts.granularity.loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	end, err := parseDate(query.Get("end"), s.opts.granularity.loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := *rc
+	v.labels = s.labels.with(query["label"])
+	per := newPeriod(&v, start, end)
+	if !per.start.Before(per.end) {
+		return nil, nil, fmt.Errorf("empty period from %s to %s", per.start.Format(DateFormat), per.end.Format(DateFormat))
+	}
+	return &v, per, nil
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Issue Analyzer</title>
+</head>
+<body style="font-family: sans-serif; margin: 2em;">
+<h1>Repos</h1>
+<ul>
+{{range .}}<li><a href="/{{.}}/">{{.}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
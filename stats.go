@@ -0,0 +1,114 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// stats are the key numbers of a repo in a period.
+type stats struct {
+	// OpenIssues and OpenPRs are counted at the end of the period.
+	OpenIssues int `json:"open_issues"`
+	OpenPRs    int `json:"open_prs"`
+	// OpenAgeMedian is the median age in days of issues open at the end
+	// of the period, and OpenAgeP25 and OpenAgeP75 are the quartiles.
+	OpenAgeMedian float64 `json:"open_age_median_days"`
+	OpenAgeP25    float64 `json:"open_age_p25_days"`
+	OpenAgeP75    float64 `json:"open_age_p75_days"`
+	// CloseTimeMedian is the median time in days from opening to closing
+	// of issues closed in the period.
+	CloseTimeMedian float64 `json:"close_time_median_days"`
+	// FirstResponseMedian is the median time in days to the first response
+	// of issues opened in the period that got one by the end of it.
+	FirstResponseMedian float64 `json:"first_response_median_days"`
+	// UntouchedMax is the longest time in days since the last comment or
+	// event, or the opening, of issues open at the end of the period.
+	UntouchedMax float64 `json:"untouched_max_days"`
+	OpenedIssues int     `json:"opened_issues"`
+	ClosedIssues int     `json:"closed_issues"`
+}
+
+// computeStats computes the stats of the repo in period [start, end).
+func computeStats(rc *repoClient, start, end time.Time) stats {
+	var st stats
+	var ages, closeTimes, responseTimes []float64
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isOpenAt(rc, i, end) {
+			if isPullRequest {
+				st.OpenPRs++
+			} else {
+				st.OpenIssues++
+				ages = append(ages, float64(end.Sub(*i.CreatedAt))/float64(DayDuration))
+				untouched := float64(end.Sub(lastTouchedAt(rc, i, end))) / float64(DayDuration)
+				st.UntouchedMax = math.Max(st.UntouchedMax, untouched)
+			}
+		}
+		if isPullRequest {
+			return
+		}
+		if inPeriod(*i.CreatedAt, start, end) {
+			st.OpenedIssues++
+			if r := rc.FirstResponse(i); r != nil && r.GetCreatedAt().Before(end) {
+				responseTimes = append(responseTimes, float64(r.GetCreatedAt().Sub(*i.CreatedAt))/float64(DayDuration))
+			}
+		}
+		if i.ClosedAt != nil && inPeriod(*i.ClosedAt, start, end) {
+			st.ClosedIssues++
+			closeTimes = append(closeTimes, float64(i.ClosedAt.Sub(*i.CreatedAt))/float64(DayDuration))
+		}
+	})
+	st.OpenAgeMedian = percentile(ages, 0.50)
+	st.OpenAgeP25 = percentile(ages, 0.25)
+	st.OpenAgeP75 = percentile(ages, 0.75)
+	st.CloseTimeMedian = percentile(closeTimes, 0.50)
+	st.FirstResponseMedian = percentile(responseTimes, 0.50)
+	return st
+}
+
+// lastTouchedAt returns the time of the last comment or event on the
+// issue at or before t, or its creation time if there is none. Events by
+// dropped accounts do not count.
+func lastTouchedAt(rc *repoClient, i github.Issue, t time.Time) time.Time {
+	last := i.GetCreatedAt()
+	for _, cm := range rc.IssueComments(i) {
+		if at := cm.GetCreatedAt(); at.After(last) && !at.After(t) {
+			last = at
+		}
+	}
+	for _, e := range rc.IssueEvents(i) {
+		if !rc.accounts.Match(e.Actor) {
+			continue
+		}
+		if at := e.GetCreatedAt(); at.After(last) && !at.After(t) {
+			last = at
+		}
+	}
+	return last
+}
+
+// isOpenAt reports whether the issue is open at t.
+func isOpenAt(rc *repoClient, i github.Issue, t time.Time) bool {
+	// issues still open are open until just after t
+	return intervalsContain(rc.OpenIntervals(i, t.Add(time.Nanosecond)), t)
+}
+
+func inPeriod(t, start, end time.Time) bool {
+	return !t.Before(start) && t.Before(end)
+}
+
+// percentile returns the q-quantile of xs by nearest rank, or 0 if xs
+// is empty. It sorts xs.
+func percentile(xs []float64, q float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sort.Float64s(xs)
+	k := int(math.Ceil(q*float64(len(xs)))) - 1
+	if k < 0 {
+		k = 0
+	}
+	return xs[k]
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// store keeps data fetched from GitHub. Records are grouped by repo
+// (e.g. "coreos/etcd") and kind (e.g. "issues"), and are keyed by a string
+// that is unique in the group.
+type store interface {
+	// SyncTime returns the sync time recorded by the last Put of the kind
+	// in repo, or zero time if nothing has been put.
+	SyncTime(repo, kind string) (time.Time, error)
+	// Put adds the records of the kind in repo, replacing the ones with
+	// the same key, and records syncedAt as the sync time.
+	Put(repo, kind string, records []record, syncedAt time.Time) error
+	// Walk calls f with the JSON data of each record of the kind in repo
+	// whose time is in [start, end), in time order. Zero start or end
+	// leaves that side of the range unbounded.
+	Walk(repo, kind string, start, end time.Time, f func(data []byte) error) error
+}
+
+type record struct {
+	Key   string
+	Time  time.Time
+	Value interface{}
+}
+
+func newStore(kind, dir string) (store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "json":
+		return &jsonStore{dir: dir, files: make(map[string]*jsonFile)}, nil
+	case "bolt":
+		return newBoltStore(filepath.Join(dir, "issue-analyzer.db"))
+	default:
+		return nil, fmt.Errorf("unknown store %q", kind)
+	}
+}
+
+// jsonStore saves each kind of records of a repo into a JSON file
+// in dir, and rewrites the whole file on Put.
+type jsonStore struct {
+	dir string
+	// files caches the files that have been read, keyed by filename.
+	files map[string]*jsonFile
+}
+
+type jsonFile struct {
+	SyncedAt time.Time             `json:"sync_time"`
+	Records  map[string]jsonRecord `json:"records"`
+}
+
+type jsonRecord struct {
+	Time  time.Time       `json:"time"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (s *jsonStore) SyncTime(repo, kind string) (time.Time, error) {
+	f, err := s.read(repo, kind)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return f.SyncedAt, nil
+}
+
+func (s *jsonStore) Put(repo, kind string, records []record, syncedAt time.Time) error {
+	f, err := s.read(repo, kind)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		data, err := json.Marshal(r.Value)
+		if err != nil {
+			return err
+		}
+		f.Records[r.Key] = jsonRecord{Time: r.Time, Value: data}
+	}
+	f.SyncedAt = syncedAt
+	return writeJson(s.filename(repo, kind), f)
+}
+
+func (s *jsonStore) Walk(repo, kind string, start, end time.Time, f func(data []byte) error) error {
+	file, err := s.read(repo, kind)
+	if err != nil {
+		return err
+	}
+	var rs []jsonRecord
+	for _, r := range file.Records {
+		if !start.IsZero() && r.Time.Before(start) {
+			continue
+		}
+		if !end.IsZero() && !r.Time.Before(end) {
+			continue
+		}
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Time.Before(rs[j].Time) })
+	for _, r := range rs {
+		if err := f(r.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read reads the file of the kind in repo. A missing or unreadable file,
+// e.g., one in an older cache format, is read as an empty file.
+func (s *jsonStore) read(repo, kind string) (*jsonFile, error) {
+	filename := s.filename(repo, kind)
+	if f, ok := s.files[filename]; ok {
+		return f, nil
+	}
+	f := &jsonFile{}
+	if err := readJson(filename, f); err != nil {
+		if _, statErr := os.Stat(filename); statErr == nil {
+			fmt.Fprintf(os.Stderr, "ignoring cache file %q (%v)\n", filename, err)
+		}
+		f = &jsonFile{}
+	}
+	if f.Records == nil {
+		f.Records = make(map[string]jsonRecord)
+	}
+	s.files[filename] = f
+	return f, nil
+}
+
+// filename returns the file of the kind in repo, which is in a directory
+// for the repo whose name has "/" escaped, so that no two repos share it.
+func (s *jsonStore) filename(repo, kind string) string {
+	return filepath.Join(s.dir, url.PathEscape(repo), kind+".cache")
+}
+
+func readJson(filename string, v interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error loading cached data from file %s (%v)", filename, err)
+	}
+	return nil
+}
+
+func writeJson(filename string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling cached data (%v)", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("error caching data into file (%v)", err)
+	}
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		return fmt.Errorf("error caching data into file (%v)", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStores(t *testing.T) {
+	for _, kind := range []string{"json", "bolt"} {
+		t.Run(kind, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "store")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			st, err := newStore(kind, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testStore(t, st)
+
+			// records are read back from the files
+			st, err = newStore(kind, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var vs []string
+			err = st.Walk("o/r", "issues", time.Time{}, time.Time{}, func(data []byte) error {
+				vs = append(vs, string(data))
+				return nil
+			})
+			if want := []string{`"b"`, `"c"`, `"d"`}; err != nil || !reflect.DeepEqual(vs, want) {
+				t.Errorf("Walk after reopening = %q, %v, want %q", vs, err, want)
+			}
+		})
+	}
+}
+
+func testStore(t *testing.T, st store) {
+	day := func(d int) time.Time { return time.Date(2017, time.January, d, 0, 0, 0, 0, time.UTC) }
+	walk := func(repo, kind string, start, end time.Time) []string {
+		var vs []string
+		err := st.Walk(repo, kind, start, end, func(data []byte) error {
+			vs = append(vs, string(data))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Walk(%q, %q) = %v", repo, kind, err)
+		}
+		return vs
+	}
+
+	if synced, err := st.SyncTime("o/r", "issues"); err != nil || !synced.IsZero() {
+		t.Errorf("SyncTime of empty store = %v, %v, want zero time", synced, err)
+	}
+	if vs := walk("o/r", "issues", time.Time{}, time.Time{}); len(vs) != 0 {
+		t.Errorf("Walk of empty store = %q, want none", vs)
+	}
+
+	rs := []record{{"3", day(3), "c"}, {"1", day(1), "a"}, {"2", day(2), "b"}}
+	if err := st.Put("o/r", "issues", rs, day(10)); err != nil {
+		t.Fatal(err)
+	}
+	// a record with a key put before is replaced, also in the time order
+	if err := st.Put("o/r", "issues", []record{{"1", day(4), "d"}}, day(11)); err != nil {
+		t.Fatal(err)
+	}
+	// repos and kinds whose names join alike are kept apart
+	if err := st.Put("o_r", "issues", []record{{"1", day(1), "x"}}, day(12)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Put("o/r", "pulls", []record{{"1", day(1), "y"}}, day(12)); err != nil {
+		t.Fatal(err)
+	}
+
+	if synced, err := st.SyncTime("o/r", "issues"); err != nil || !synced.Equal(day(11)) {
+		t.Errorf("SyncTime = %v, %v, want %v", synced, err, day(11))
+	}
+	tests := []struct {
+		repo, kind string
+		start, end time.Time
+		want       []string
+	}{
+		{"o/r", "issues", time.Time{}, time.Time{}, []string{`"b"`, `"c"`, `"d"`}},
+		{"o/r", "issues", day(3), time.Time{}, []string{`"c"`, `"d"`}},
+		{"o/r", "issues", time.Time{}, day(3), []string{`"b"`}},
+		{"o/r", "issues", day(2), day(4), []string{`"b"`, `"c"`}},
+		{"o/r", "issues", day(5), time.Time{}, nil},
+		{"o_r", "issues", time.Time{}, time.Time{}, []string{`"x"`}},
+		{"o/r", "pulls", time.Time{}, time.Time{}, []string{`"y"`}},
+	}
+	for _, tt := range tests {
+		if got := walk(tt.repo, tt.kind, tt.start, tt.end); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Walk(%q, %q, %v, %v) = %q, want %q", tt.repo, tt.kind, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// survivalHorizon is the most days since opening to draw survival curves
+// for.
+const survivalHorizon = 730
+
+// survivalGroups are the ways to group issues into survival curves, where
+// empty draws one curve of all issues.
+var survivalGroups = []string{"", "year", "label"}
+
+// observation is the time in days that an issue was observed from opening,
+// and whether it was closed then, or is censored as still open.
+type observation struct {
+	days   float64
+	closed bool
+}
+
+// kaplanMeier returns the Kaplan–Meier estimate of the fraction of issues
+// still open after each of whole days 0 to n-1. It is flat after the
+// longest observation.
+func kaplanMeier(obs []observation, n int) seqFloats {
+	sort.Slice(obs, func(i, j int) bool {
+		if obs[i].days != obs[j].days {
+			return obs[i].days < obs[j].days
+		}
+		// closes before censoring at the same time, as usual
+		return obs[i].closed && !obs[j].closed
+	})
+	fs := make(seqFloats, n)
+	s, atRisk, j := 1.0, len(obs), 0
+	for k := range fs {
+		for j < len(obs) && obs[j].days <= float64(k) {
+			t, closed, left := obs[j].days, 0, 0
+			for ; j < len(obs) && obs[j].days == t; j++ {
+				if obs[j].closed {
+					closed++
+				} else {
+					left++
+				}
+			}
+			s *= 1 - float64(closed)/float64(atRisk)
+			atRisk -= closed + left
+		}
+		fs[k] = s
+	}
+	return fs
+}
+
+// survivalChart draws the fraction of issues opened in the period that
+// are still open after each number of days, where issues open at the end
+// of the period are censored rather than counted as never closed. by groups
+// issues by the year opened in loc, or by the labels matching patterns, or
+// the top labels of open issues if there are no patterns.
+func survivalChart(rc *repoClient, per *period, by string, patterns []string, loc *time.Location) *chart {
+	if by == "label" && len(patterns) == 0 {
+		patterns = topOpenLabels(rc, 5)
+	}
+
+	var names []string
+	groups := make(map[string][]observation)
+	longest := 0.0
+	rc.WalkIssues(func(i github.Issue, isPullRequest bool) {
+		if isPullRequest || !inPeriod(*i.CreatedAt, per.start, per.end) {
+			return
+		}
+		o := observation{days: float64(per.end.Sub(*i.CreatedAt)) / float64(DayDuration)}
+		if i.ClosedAt != nil && i.ClosedAt.Before(per.end) {
+			o = observation{days: float64(i.ClosedAt.Sub(*i.CreatedAt)) / float64(DayDuration), closed: true}
+		}
+		if o.days > longest {
+			longest = o.days
+		}
+
+		var keys []string
+		switch by {
+		case "year":
+			keys = []string{strconv.Itoa(i.CreatedAt.In(loc).Year())}
+		case "label":
+			for _, pattern := range patterns {
+				for _, l := range i.Labels {
+					if matchLabel(pattern, l.GetName()) {
+						keys = append(keys, pattern)
+						break
+					}
+				}
+			}
+		default:
+			keys = []string{""}
+		}
+		for _, key := range keys {
+			if _, ok := groups[key]; !ok {
+				names = append(names, key)
+			}
+			groups[key] = append(groups[key], o)
+		}
+	})
+	if by == "label" {
+		// keep the order of patterns
+		names = nil
+		for _, pattern := range patterns {
+			if _, ok := groups[pattern]; ok {
+				names = append(names, pattern)
+			}
+		}
+	} else {
+		sort.Strings(names)
+	}
+
+	n := int(longest) + 1
+	if n > survivalHorizon+1 {
+		n = survivalHorizon + 1
+	}
+	days := make([]string, n)
+	for k := range days {
+		days[k] = strconv.Itoa(k)
+	}
+	c := &chart{
+		title:  "Survival of Issues",
+		xLabel: fmt.Sprintf("Days since opened, of issues opened from %s to %s", per.start.In(loc).Format(DateFormat), per.end.In(loc).Format(DateFormat)),
+		yLabel: "Fraction open",
+		names:  days,
+		lines:  true,
+	}
+	for _, name := range names {
+		c.add(name, kaplanMeier(groups[name], n))
+	}
+	return c
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKaplanMeier(t *testing.T) {
+	tests := []struct {
+		name string
+		obs  []observation
+		n    int
+		want []float64
+	}{
+		{"none", nil, 3, []float64{1, 1, 1}},
+		{"closed on opening", []observation{{0, true}}, 2, []float64{0, 0}},
+		{"closed within a day", []observation{{0.5, true}}, 2, []float64{1, 0}},
+		{"censored only", []observation{{1, false}, {2, false}}, 3, []float64{1, 1, 1}},
+		// 2 of 5 close on day 1, when one is censored, and 1 of the 2 left
+		// closes on day 3
+		{"tied", []observation{{5, false}, {1, true}, {3, true}, {1, false}, {1, true}}, 7, []float64{1, 0.6, 0.6, 0.3, 0.3, 0.3, 0.3}},
+		// an issue censored at the time of a close is still at risk then
+		{"censored at close", []observation{{2, false}, {2, true}}, 3, []float64{1, 1, 0.5}},
+	}
+	for _, tt := range tests {
+		got := kaplanMeier(tt.obs, tt.n)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: kaplanMeier() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for k := range got {
+			if math.Abs(got[k]-tt.want[k]) > 1e-9 {
+				t.Errorf("%s: kaplanMeier() = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}